@@ -0,0 +1,11 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+//go:build !faultrelease
+// +build !faultrelease
+
+package fault
+
+// Release is true when the package is built with the faultrelease build tag.
+// Release builds default to the Simple faulter and do not capture stack traces.
+const Release = false
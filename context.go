@@ -0,0 +1,137 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Context raises a fault carrying context.Cause(ctx) if ctx is done. The
+// fault carries the fields extracted from ctx.
+//
+//	for _, item := range items {
+//		check.Context(ctx)
+//		process(item)
+//	}
+func (c *Checker) Context(ctx context.Context) {
+	if ctx.Err() != nil {
+		c.raise(WithFields(context.Cause(ctx), ContextFields(ctx)...))
+	}
+}
+
+// ContextChecker is a FaultCheck bound to a context. Faults raised by it carry
+// the fields extracted from the context by the registered ContextExtractors,
+// along with a public message if one was set using Public.
+type ContextChecker struct {
+	checker *Checker
+	ctx     context.Context
+	cancel  bool
+	public  string
+}
+
+var contextCheck = NewChecker()
+
+// WithContext returns a ContextChecker bound to ctx using a default checker.
+//
+//	data := fault.WithContext(ctx).Return(fetch(ctx, url)).([]byte)
+func WithContext(ctx context.Context) *ContextChecker { return contextCheck.WithContext(ctx) }
+
+// WithContext returns a ContextChecker bound to ctx which raises faults using
+// c. Every check raises a fault carrying the cause of the context if it is
+// done, before performing the check itself.
+func (c *Checker) WithContext(ctx context.Context) *ContextChecker {
+	return &ContextChecker{checker: c, ctx: ctx, cancel: true}
+}
+
+// In returns a ContextChecker bound to ctx which raises faults using c. Unlike
+// WithContext it does not check whether the context is done.
+//
+//	func (s *Server) handle(ctx context.Context, req *Request) (err error) {
+//		check := s.check.In(ctx)
+//		defer check.Recover(&err)
+//		...
+//	}
+func (c *Checker) In(ctx context.Context) *ContextChecker {
+	return &ContextChecker{checker: c, ctx: ctx}
+}
+
+// Public returns a copy of c whose faults carry msg as their public message.
+func (c *ContextChecker) Public(msg string) *ContextChecker {
+	public := *c
+	public.public = msg
+	return &public
+}
+
+func (c *ContextChecker) annotate(err error) error {
+	return WithPublic(WithFields(err, ContextFields(c.ctx)...), c.public)
+}
+
+func (c *ContextChecker) raise(err error) {
+	c.checker.raise(c.annotate(err))
+}
+
+func (c *ContextChecker) checkDone() {
+	if c.cancel {
+		c.checker.Context(c.ctx)
+	}
+}
+
+// Recover implements FaultCheck.Recover
+func (c *ContextChecker) Recover(errPtr *error) {
+	c.checker.RecoverPanic(errPtr, recover())
+}
+
+// RecoverPanic implements FaultCheck.RecoverPanic
+func (c *ContextChecker) RecoverPanic(errPtr *error, panicked interface{}) {
+	c.checker.RecoverPanic(errPtr, panicked)
+}
+
+// True implements FaultCheck.True
+func (c *ContextChecker) True(condition bool, errStr string) {
+	c.checkDone()
+	if !condition {
+		c.raise(errors.New(errStr))
+	}
+}
+
+// Truef implements FaultCheck.Truef
+func (c *ContextChecker) Truef(condition bool, format string, args ...interface{}) {
+	c.checkDone()
+	if !condition {
+		c.raise(&templateError{error: fmt.Errorf(format, args...), format: format})
+	}
+}
+
+// Return implements FaultCheck.Return
+func (c *ContextChecker) Return(i interface{}, err error) interface{} {
+	c.checkDone()
+	if err != nil {
+		c.raise(err)
+	}
+	return i
+}
+
+// Error implements FaultCheck.Error
+func (c *ContextChecker) Error(err error) {
+	c.checkDone()
+	if err != nil {
+		c.raise(err)
+	}
+}
+
+// Output implements FaultCheck.Output
+func (c *ContextChecker) Output(i interface{}, err error) interface{} {
+	c.checkDone()
+	if err != nil {
+		c.raise(outputError(i, err))
+	}
+	return i
+}
+
+// Failure implements FaultCheck.Failure
+func (c *ContextChecker) Failure(err error) Fault {
+	return c.checker.Failure(c.annotate(err))
+}
@@ -0,0 +1,75 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ FaultCheck = &ContextChecker{}
+
+func TestCheckContext(t *testing.T) {
+	check := NewChecker().SetFaulter(Simple)
+	cause := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	processed := 0
+	err := func() (err error) {
+		defer check.Recover(&err)
+		for i := 0; i < 10; i++ {
+			check.Context(ctx)
+			if processed++; processed == 3 {
+				cancel(cause)
+			}
+		}
+		return
+	}()
+	if !errors.Is(err, cause) || processed != 3 {
+		t.Error("Unexpected result", err, processed)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	bound := NewChecker().SetFaulter(Simple).WithContext(ctx)
+	for _, fn := range []func(){
+		func() { bound.True(true, "") },
+		func() { bound.Truef(true, "") },
+		func() { bound.Return(nil, nil) },
+		func() { bound.Error(nil) },
+		func() { bound.Output(nil, nil) },
+	} {
+		err := func() (err error) {
+			defer bound.Recover(&err)
+			fn()
+			return
+		}()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Error("Expected deadline exceeded found", err)
+		}
+	}
+
+	live := WithContext(context.Background())
+	err := func() (err error) {
+		defer live.Recover(&err)
+		if live.Return("ok", nil).(string) != "ok" {
+			t.Error("Unexpected return")
+		}
+		live.Error(errors.New("error1"))
+		return
+	}()
+	if err == nil || !strings.HasSuffix(err.Error(), "error1") {
+		t.Error("Unexpected error", err)
+	}
+	if site := StartSite(GetTrace(err)).Name; !Release && !strings.HasPrefix(site, "github.com/surullabs/fault.TestWithContext.") {
+		t.Error("Unexpected start site", site)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// DefaultOutputLimit is the number of bytes of each output kept by ExecOutput.
+const DefaultOutputLimit = 4096
+
+// TruncatedOutput holds the output of a command, limited to its head and tail.
+type TruncatedOutput struct {
+	Head   string // Head is the start of the output
+	Tail   string // Tail is the end of the output if it was truncated
+	Size   int    // Size is the total number of bytes of output
+	Elided int    // Elided is the number of bytes dropped between Head and Tail
+}
+
+// Truncate keeps at most limit bytes of out, split evenly between its head and
+// tail. The cuts are moved to rune boundaries, so fewer bytes may be kept. A
+// limit of zero or less keeps all output.
+func Truncate(out []byte, limit int) TruncatedOutput {
+	t := TruncatedOutput{Size: len(out)}
+	if limit <= 0 || len(out) <= limit {
+		t.Head = string(out)
+		return t
+	}
+	head, tail := limit/2, len(out)-(limit-limit/2)
+	for head > 0 && !utf8.RuneStart(out[head]) {
+		head--
+	}
+	for tail < len(out) && !utf8.RuneStart(out[tail]) {
+		tail++
+	}
+	t.Head, t.Tail = string(out[:head]), string(out[tail:])
+	t.Elided = tail - head
+	return t
+}
+
+func (t TruncatedOutput) String() string {
+	if t.Elided == 0 {
+		return t.Head
+	}
+	return fmt.Sprintf("%s[... %d bytes elided ...]%s", t.Head, t.Elided, t.Tail)
+}
+
+// CommandError describes a command which failed.
+type CommandError struct {
+	Err    error           // Err is the error returned by os/exec
+	Code   int             // Code is the exit code or -1 if the command did not exit normally
+	Signal string          // Signal names the signal which killed the command, if any
+	Output TruncatedOutput // Output is the output returned along with Err
+	Stderr TruncatedOutput // Stderr is the standard error captured in exec.ExitError
+}
+
+// NewCommandError returns a CommandError for the output and error returned by
+// one of the os/exec output methods, keeping limit bytes of each output.
+func NewCommandError(out []byte, err error, limit int) *CommandError {
+	c := &CommandError{Err: err, Code: -1, Output: Truncate(out, limit)}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		c.Code = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			c.Signal = status.Signal().String()
+		}
+		c.Stderr = Truncate(exitErr.Stderr, limit)
+	}
+	return c
+}
+
+func (c *CommandError) Error() string {
+	parts := []string{c.Err.Error()}
+	if c.Stderr.Size > 0 {
+		parts = append(parts, fmt.Sprintf("stderr (%d bytes): %s", c.Stderr.Size, c.Stderr))
+	}
+	if c.Output.Size > 0 {
+		parts = append(parts, fmt.Sprintf("output (%d bytes): %s", c.Output.Size, c.Output))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Fields returns the exit code, signal and output sizes of the command. They
+// are included in the result of the package level Fields function.
+func (c *CommandError) Fields() []Field {
+	fields := []Field{{Key: "exit_code", Value: c.Code}}
+	if c.Signal != "" {
+		fields = append(fields, Field{Key: "signal", Value: c.Signal})
+	}
+	return append(fields, Field{Key: "output_bytes", Value: c.Output.Size}, Field{Key: "stderr_bytes", Value: c.Stderr.Size})
+}
+
+// Unwrap returns the error returned by os/exec.
+func (c *CommandError) Unwrap() error { return c.Err }
+
+// ExitCode implements ExitCoder, allowing Main to pass the exit code through.
+func (c *CommandError) ExitCode() int { return c.Code }
+
+// ExecOutput works like Output for the results of the os/exec output methods.
+// On failure it raises a fault with a *CommandError, which records the exit
+// status and truncated output.
+//
+//	out := check.ExecOutput(exec.Command("git", "status").Output())
+func (c *Checker) ExecOutput(out []byte, err error) []byte {
+	if err != nil {
+		c.raise(NewCommandError(out, err, DefaultOutputLimit))
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, test := range []struct {
+		out    string
+		limit  int
+		result string
+		elided int
+	}{
+		{"", 4, "", 0},
+		{"abcd", 4, "abcd", 0},
+		{"abcdef", 0, "abcdef", 0},
+		{"abcdefgh", 4, "ab[... 4 bytes elided ...]gh", 4},
+		{"abcdefgh", 3, "a[... 5 bytes elided ...]gh", 5},
+		{"ééééé", 3, "[... 8 bytes elided ...]é", 8},
+		{"aéééb", 6, "aé[... 2 bytes elided ...]éb", 2},
+	} {
+		truncated := Truncate([]byte(test.out), test.limit)
+		if truncated.String() != test.result || truncated.Elided != test.elided || truncated.Size != len(test.out) {
+			t.Error("Unexpected truncation", truncated)
+		}
+	}
+}
+
+func runExec(cmd *exec.Cmd, output func(*exec.Cmd) ([]byte, error)) (out []byte, err error) {
+	c := NewChecker().SetFaulter(Simple)
+	defer c.Recover(&err)
+	out = c.ExecOutput(output(cmd))
+	return
+}
+
+func TestExecOutput(t *testing.T) {
+	out, err := runExec(exec.Command("/bin/sh", "-c", "echo ok"), (*exec.Cmd).Output)
+	if err != nil || string(out) != "ok\n" {
+		t.Error("Unexpected result", string(out), err)
+	}
+
+	_, err = runExec(exec.Command("/bin/sh", "-c", "echo out; echo err >&2; exit 3"), (*exec.Cmd).Output)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatal("Expected command error found", err)
+	}
+	if cmdErr.Code != 3 || cmdErr.Signal != "" || cmdErr.Stderr.Head != "err\n" || cmdErr.Output.Head != "out\n" {
+		t.Error("Unexpected command error", cmdErr)
+	}
+	if err.Error() != "exit status 3; stderr (4 bytes): err\n; output (4 bytes): out\n" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	if ExitCode(err) != 3 {
+		t.Error("Exit code not passed through")
+	}
+	if fields := Fields(err); len(fields) != 3 || fields[0] != (Field{"exit_code", 3}) ||
+		fields[1] != (Field{"output_bytes", 4}) || fields[2] != (Field{"stderr_bytes", 4}) {
+		t.Error("Unexpected fields", fields)
+	}
+
+	_, err = runExec(exec.Command("/bin/sh", "-c", "head -c 100000 /dev/zero | tr '\\0' x; kill -9 $$"), (*exec.Cmd).CombinedOutput)
+	if !errors.As(err, &cmdErr) {
+		t.Fatal("Expected command error found", err)
+	}
+	if cmdErr.Code != -1 || cmdErr.Signal != "killed" {
+		t.Error("Signal not recorded", cmdErr.Code, cmdErr.Signal)
+	}
+	if fields := Fields(err); len(fields) < 2 || fields[1] != (Field{"signal", "killed"}) {
+		t.Error("Signal field missing", fields)
+	}
+
+	if cmdErr.Output.Size != 100000 || len(err.Error()) > 2*DefaultOutputLimit ||
+		!strings.Contains(err.Error(), "output (100000 bytes): ") || !strings.Contains(err.Error(), "bytes elided") {
+		t.Error("Output not truncated", len(err.Error()))
+	}
+
+	// Core dumps may be written to the working directory of the command.
+	abort := exec.Command("/bin/sh", "-c", "ulimit -c unlimited 2>/dev/null; kill -ABRT $$")
+	abort.Dir = t.TempDir()
+	_, err = runExec(abort, (*exec.Cmd).Output)
+	if !errors.As(err, &cmdErr) || cmdErr.Signal != "aborted" {
+		t.Error("Unexpected signal for abort", err)
+	}
+
+	_, err = runExec(exec.Command("/nonexistent/binary"), (*exec.Cmd).Output)
+	if !errors.As(err, &cmdErr) || cmdErr.Code != -1 || cmdErr.Output.Size != 0 {
+		t.Error("Unexpected error for missing binary", err)
+	}
+}
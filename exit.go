@@ -0,0 +1,137 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes as defined by sysexits.h.
+const (
+	ExitOK          = 0
+	ExitFailure     = 1
+	ExitUsage       = 64 // command line usage error
+	ExitDataErr     = 65 // data format error
+	ExitNoInput     = 66 // cannot open input
+	ExitNoUser      = 67 // addressee unknown
+	ExitNoHost      = 68 // host name unknown
+	ExitUnavailable = 69 // service unavailable
+	ExitSoftware    = 70 // internal software error
+	ExitOSErr       = 71 // system error
+	ExitOSFile      = 72 // critical OS file missing
+	ExitCantCreate  = 73 // can't create (user) output file
+	ExitIOErr       = 74 // input/output error
+	ExitTempFail    = 75 // temporary failure; user is invited to retry
+	ExitProtocol    = 76 // remote error in protocol
+	ExitNoPerm      = 77 // permission denied
+	ExitConfig      = 78 // configuration error
+)
+
+// VerboseEnv is the environment variable which makes Main print the full
+// trace of an error when set to 1.
+const VerboseEnv = "FAULT_VERBOSE"
+
+// ExitCoder is implemented by errors which carry a process exit code, such as
+// *exec.ExitError.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+type exitError struct {
+	error
+	code int
+}
+
+func (e *exitError) ExitCode() int { return e.code }
+func (e *exitError) Unwrap() error { return e.error }
+
+// WithExitCode returns an error which makes Main exit with code.
+//
+//	check.Error(fault.WithExitCode(err, fault.ExitConfig))
+func WithExitCode(err error, code int) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{error: err, code: code}
+}
+
+// ExitCode maps err to a process exit code. Errors implementing ExitCoder
+// provide their own code, with exit codes of processes killed by a signal
+// mapped to ExitFailure. Common error classes map to their sysexits
+// equivalents and all other errors map to ExitFailure.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		if code := coder.ExitCode(); code >= 0 {
+			return code
+		}
+		return ExitFailure
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ExitNoInput
+	case errors.Is(err, os.ErrPermission):
+		return ExitNoPerm
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTempFail
+	}
+	return ExitFailure
+}
+
+// Program configures the behavior of Main.
+type Program struct {
+	// Stderr receives the error message. It defaults to os.Stderr.
+	Stderr io.Writer
+	// ExitCode maps errors to exit codes. It defaults to ExitCode.
+	ExitCode func(error) int
+	// Exit terminates the process. It defaults to os.Exit.
+	Exit func(int)
+}
+
+var mainCheck = NewChecker()
+
+// Main runs a command line program using the default Program. It does not
+// return.
+//
+//	func main() {
+//		fault.Main(run)
+//	}
+func Main(run func() error) { (&Program{}).Main(run) }
+
+// Main calls run, recovering any fault it raises. If run fails the error is
+// printed to Stderr, including its trace if FAULT_VERBOSE=1 is set, and the
+// program exits with the code mapped from the error. Non-fault panics are
+// propagated.
+func (p *Program) Main(run func() error) {
+	stderr, exitCode, exit := p.Stderr, p.ExitCode, p.Exit
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	if exitCode == nil {
+		exitCode = ExitCode
+	}
+	if exit == nil {
+		exit = os.Exit
+	}
+
+	err := func() (err error) {
+		defer mainCheck.Recover(&err)
+		return run()
+	}()
+	if err != nil {
+		if os.Getenv(VerboseEnv) == "1" {
+			fmt.Fprintln(stderr, VerboseTrace(err))
+		} else {
+			fmt.Fprintln(stderr, Redact(err.Error()))
+		}
+	}
+	exit(exitCode(err))
+}
@@ -0,0 +1,86 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	exitErr := exec.Command("/bin/sh", "-c", "exit 3").Run()
+	_, notExist := os.Open("/nonexistent")
+	for _, test := range []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, ExitOK},
+		{"plain", errors.New("failed"), ExitFailure},
+		{"with code", WithExitCode(errors.New("failed"), ExitConfig), ExitConfig},
+		{"exec", exitErr, 3},
+		{"exec chained", Chain(errors.New("first"), exitErr), 3},
+		{"not exist", notExist, ExitNoInput},
+		{"permission", fmt.Errorf("wrapped: %w", os.ErrPermission), ExitNoPerm},
+		{"deadline", context.DeadlineExceeded, ExitTempFail},
+		{"traced", Traced(WithExitCode(errors.New("failed"), ExitUsage)), ExitUsage},
+	} {
+		if code := ExitCode(test.err); code != test.code {
+			t.Error(test.name, "expected", test.code, "found", code)
+		}
+	}
+	if WithExitCode(nil, ExitUsage) != nil {
+		t.Error("Nil error wrapped")
+	}
+}
+
+func TestProgramMain(t *testing.T) {
+	check := NewChecker().SetFaulter(Simple)
+	for _, test := range []struct {
+		name    string
+		program Program
+		verbose bool
+		run     func() error
+		out     string
+		code    int
+	}{
+		{"success", Program{}, false, func() error { return nil }, "", 0},
+		{"error", Program{}, false, func() error { return errors.New("failed") }, "failed\n", 1},
+		{"fault", Program{}, false, func() error {
+			check.Error(WithExitCode(errors.New("bad usage"), ExitUsage))
+			return nil
+		}, "bad usage\n", ExitUsage},
+		{"custom", Program{ExitCode: func(error) int { return 42 }}, false,
+			func() error { return errors.New("failed") }, "failed\n", 42},
+		{"verbose", Program{}, true, func() error { return Traced(errors.New("failed")) }, "failed\n", 1},
+	} {
+		var stderr bytes.Buffer
+		code := -1
+		test.program.Stderr = &stderr
+		test.program.Exit = func(c int) { code = c }
+		if test.verbose {
+			os.Setenv(VerboseEnv, "1")
+		}
+		test.program.Main(test.run)
+		os.Unsetenv(VerboseEnv)
+
+		if code != test.code {
+			t.Error(test.name, "expected code", test.code, "found", code)
+		}
+		out := stderr.String()
+		if test.verbose && !Release {
+			if !strings.Contains(out, "failed\n") || strings.Count(out, "\n") < 2 {
+				t.Error(test.name, "expected verbose output found", out)
+			}
+		} else if !strings.HasSuffix(out, test.out) {
+			t.Error(test.name, "expected", test.out, "found", out)
+		}
+	}
+}
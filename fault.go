@@ -32,6 +32,9 @@ from a file and then writing it you could use
 It also provides access to an ErrorChain class which can be used to chain errors together.
 Errors can be transparently checked for existence in a chain by calling the Contains method.
 
+Checkers can be registered by name using Register, which allows debug tracing to be
+switched on and off at runtime through SetTracing or the FAULT_TRACE environment variable.
+
 Please look at the tests for more sample usage.
 */
 package fault
@@ -43,6 +46,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
 )
 
 // ErrorChain is a list of errors and can be used to chain errors together.
@@ -64,6 +68,10 @@ func (c *ErrorChain) String() string { return c.Error() }
 // Errors returns all errors in the chain
 func (c *ErrorChain) Errors() []error { return c.chain }
 
+// Unwrap returns all errors in the chain, allowing errors.Is and errors.As to
+// inspect them.
+func (c *ErrorChain) Unwrap() []error { return c.chain }
+
 // Error will return a string representation of all errors.
 func (c *ErrorChain) Error() string {
 	errors := make([]string, len(c.chain))
@@ -177,9 +185,17 @@ type FaultCheck interface {
 
 // Checker provides a default implementation of FaultCheck
 type Checker struct {
-	faulter Faulter
+	faulter atomic.Value // faulterValue
+	debug   atomic.Value // faulterValue holding the last tracing faulter set
+	hooks   hookSet
+	panics  int32 // PanicPolicy
+	stacks  int32 // non-zero if re-panics carry the original stack
 }
 
+// faulterValue wraps a Faulter so that implementations of different types can
+// be stored in the same atomic.Value.
+type faulterValue struct{ Faulter }
+
 // NewChecker returns a new checker that includes stack traces with errors.
 //
 // 	var check fault.FaultCheck = fault.NewChecker()
@@ -187,22 +203,49 @@ type Checker struct {
 // If you don't want the overhead of accumulating stack traces then use
 //
 //	var check fault.FaultCheck = fault.NewChecker().SetFaulter(fault.Simple)
-func NewChecker() *Checker { return &Checker{faulter: &DebugFaulter{}} }
+//
+// When built with the faultrelease tag the checker uses WithIDs(Simple)
+// instead, so that faults still carry an id for use with Reference.
+func NewChecker() *Checker {
+	if Release {
+		return (&Checker{}).SetFaulter(WithIDs(Simple))
+	}
+	return (&Checker{}).SetFaulter(&DebugFaulter{})
+}
 
+// SetFaulter sets the Faulter used to generate faults. It is safe to call
+// concurrently with other uses of the checker. A DebugFaulter set here is
+// remembered and restored when tracing is later re-enabled with SetTracing.
 func (c *Checker) SetFaulter(f Faulter) *Checker {
-	c.faulter = f
+	if isTracing(f) {
+		c.debug.Store(faulterValue{f})
+	}
+	c.faulter.Store(faulterValue{f})
 	return c
 }
 
+// Faulter returns the Faulter currently used to generate faults.
+func (c *Checker) Faulter() Faulter {
+	if f, ok := c.faulter.Load().(faulterValue); ok {
+		return f.Faulter
+	}
+	return Simple
+}
+
 // RecoverPanic implements FaultCheck.RecoverPanic
 func (c *Checker) RecoverPanic(errPtr *error, panicked interface{}) {
 	if panicked == nil {
 		return
 	} else if fault, faulty := panicked.(Fault); faulty {
 		*errPtr = Chain(fault.Cause(), *errPtr)
+		c.hooks.recovered(fault.Cause())
+		return
+	} else if err := c.convertPanic(panicked); err != nil {
+		*errPtr = Chain(err, *errPtr)
+		c.hooks.recovered(err)
 		return
 	} else {
-		panic(panicked)
+		panic(c.repanicValue(panicked))
 	}
 }
 
@@ -224,6 +267,7 @@ type errorFault struct {
 
 func (e *errorFault) Error() string { return e.err.Error() }
 func (e *errorFault) Cause() error  { return e.err }
+func (e *errorFault) Unwrap() error { return e.err }
 
 func (e *errorFault) String() string {
 	if e.err == nil {
@@ -233,23 +277,31 @@ func (e *errorFault) String() string {
 
 }
 
+// raise panics with a fault generated from err after notifying any hooks. The
+// message of err is redacted.
+func (c *Checker) raise(err error) {
+	fault := c.Faulter().New(redactError(err))
+	c.hooks.raised(fault)
+	panic(fault)
+}
+
 func (c *Checker) True(condition bool, errStr string) {
 	if !condition {
-		panic(c.faulter.New(errors.New(errStr)))
+		c.raise(errors.New(errStr))
 	}
 }
 
 // True implements FaultCheck.True
 func (c *Checker) Truef(condition bool, format string, args ...interface{}) {
 	if !condition {
-		panic(c.faulter.New(fmt.Errorf(format, args...)))
+		c.raise(&templateError{error: fmt.Errorf(format, args...), format: format})
 	}
 }
 
 // Return implements FaultCheck.Return
 func (c *Checker) Return(i interface{}, err error) interface{} {
 	if err != nil {
-		panic(c.faulter.New(err))
+		c.raise(err)
 	}
 	return i
 }
@@ -257,26 +309,31 @@ func (c *Checker) Return(i interface{}, err error) interface{} {
 // Error implements FaultCheck.Error
 func (c *Checker) Error(err error) {
 	if err != nil {
-		panic(c.faulter.New(err))
+		c.raise(err)
 	}
 }
 
 // Output implements FaultCheck.Output
 func (c *Checker) Output(i interface{}, err error) interface{} {
 	if err != nil {
-		var out string
-		if bytes, isByteArray := i.([]byte); isByteArray {
-			out = string(bytes)
-		} else {
-			out = fmt.Sprintf("%v", i)
-		}
-		panic(c.faulter.New(&ErrorChain{chain: []error{err, fmt.Errorf("output: %s", out)}}))
+		c.raise(outputError(i, err))
 	}
 	return i
 }
 
+// outputError returns the error raised by Output.
+func outputError(i interface{}, err error) error {
+	var out string
+	if bytes, isByteArray := i.([]byte); isByteArray {
+		out = string(bytes)
+	} else {
+		out = fmt.Sprintf("%v", i)
+	}
+	return &ErrorChain{chain: []error{err, fmt.Errorf("output: %s", out)}}
+}
+
 func (c *Checker) Failure(err error) Fault {
-	return c.faulter.New(err)
+	return c.Faulter().New(redactError(err))
 }
 
 // Call provides information about a function call.
@@ -286,7 +343,12 @@ type Call struct {
 	Name string // Name is the name of the calling function
 }
 
-func (c *Call) String() string { return fmt.Sprintf("%s:%d", filepath.Base(c.File), c.Line) }
+func (c *Call) String() string {
+	if n, elided := c.Elided(); elided {
+		return fmt.Sprintf("... %d frames elided", n)
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(c.File), c.Line)
+}
 
 func (c *Call) Equal(c2 *Call) bool {
 	if c == nil {
@@ -300,6 +362,7 @@ func (c *Call) Equal(c2 *Call) bool {
 type debugFault struct {
 	err   error
 	trace []Call
+	id    *faultID
 }
 
 func GetTrace(err error) (trace []Call) {
@@ -327,19 +390,18 @@ func (d *debugFault) Error() string {
 
 func (d *debugFault) Cause() error { return d }
 
+// Unwrap returns the error the fault was created from.
+func (d *debugFault) Unwrap() error { return d.err }
+
+// DebugFaulter generates faults which include the stack trace of the call site.
+// The trace starts at the first caller which is not a helper (see Helper). If
+// Prefix is set the trace instead starts after the functions matching it, as
+// described in ReadStack.
 type DebugFaulter struct {
 	Prefix string
+	Filter *TraceFilter // Filter is applied to every trace if set
 }
 
-func (d DebugFaulter) prefix() string {
-	if d.Prefix != "" {
-		return d.Prefix
-	}
-	return checkerPrefix
-}
-
-var checkerPrefix = TypePrefix(&Checker{})
-
 func TypePrefix(i interface{}) string {
 	val := reflect.ValueOf(i)
 	if val.Kind() == reflect.Ptr {
@@ -352,9 +414,12 @@ func TypePrefix(i interface{}) string {
 
 // ReadStack reads returns the stack after ignoring all calls up to the
 // function which has the first parameter as a prefix . An empty string returns
-// the entire stack.
+// the entire stack. Release builds always return an empty stack.
 func ReadStack(prefix string) (trace []Call) {
 	trace = make([]Call, 0)
+	if Release {
+		return
+	}
 	var (
 		pc uintptr
 		fn *runtime.Func
@@ -369,42 +434,75 @@ func ReadStack(prefix string) (trace []Call) {
 			call.Name = fn.Name()
 		}
 
-		if appendTo {
+		// Consecutive frames matching the prefix are all skipped so that
+		// internal helpers do not show up as the start of the trace.
+		matched := strings.HasPrefix(call.Name, prefix)
+		if appendTo && !(matched && prefix != "" && len(trace) == 0) {
 			trace = append(trace, call)
 		}
-		if strings.HasPrefix(call.Name, prefix) {
+		if matched {
 			appendTo = true
 		}
 	}
 	return
 }
 
+// New implements Faulter.New. Release builds return the same faults as Simple.
 func (d DebugFaulter) New(err error) Fault {
-	return &debugFault{err: err, trace: ReadStack(d.prefix())}
+	if Release {
+		return Simple.New(err)
+	}
+	var trace []Call
+	if d.Prefix != "" {
+		trace = ReadStack(d.Prefix)
+	} else {
+		trace = Callers(1)
+	}
+	return &debugFault{err: err, trace: d.Filter.Apply(trace), id: new(faultID)}
 }
 
-// Traced returns an error with the entire stack trace
-func Traced(err error) error {
+// Traced returns an error with the entire stack trace. Release builds return
+// err unchanged.
+func Traced(err error) error { return traced(err, nil) }
+
+// traced must be called directly by the exported function whose caller is the
+// start of the trace.
+func traced(err error, filter *TraceFilter) error {
+	if Release {
+		return err
+	}
 	if chain, isChain := err.(*ErrorChain); isChain && len(chain.Errors()) == 1 {
 		err = chain.Errors()[0]
 	}
 	if _, ok := err.(*debugFault); ok {
 		return err
 	}
-	trace := ReadStack("")
-	return &debugFault{err: err, trace: trace[1:]}
+	if f, isFault := err.(*errorFault); isFault {
+		err = f.err
+	}
+	id := new(faultID)
+	var ided *idError
+	if errors.As(err, &ided) {
+		id = ided.id
+	}
+	return &debugFault{err: err, trace: filter.Apply(Callers(2)), id: id}
 }
 
 func VerboseTrace(err error) string {
+	return verboseTrace(err, func(c Call) string { return c.String() })
+}
+
+// verboseTrace renders the trace of err, redacting the result.
+func verboseTrace(err error, format func(Call) string) string {
 	trace := GetTrace(err)
-	if trace == nil {
-		return err.Error()
+	if len(trace) == 0 {
+		return Redact(err.Error())
 	}
 
 	parts := make([]string, len(trace))
 	for i := range trace {
-		parts[i] = trace[i].String()
+		parts[i] = format(trace[i])
 	}
 	parts[0] = err.Error()
-	return strings.Join(parts, "\n")
+	return Redact(strings.Join(parts, "\n"))
 }
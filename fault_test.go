@@ -6,6 +6,7 @@ package fault
 import (
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"runtime"
 	"strings"
@@ -228,6 +229,28 @@ func TestRecover(t *testing.T) {
 	}
 }
 
+type causeError struct{ code int }
+
+func (e *causeError) Error() string { return "cause" }
+
+func TestSimpleCause(t *testing.T) {
+	typed := &causeError{code: 3}
+	for _, raised := range []error{io.EOF, typed} {
+		err := func() (err error) {
+			defer check.Recover(&err)
+			check.Error(raised)
+			return
+		}()
+		chain, isChain := err.(*ErrorChain)
+		if !isChain || len(chain.Errors()) != 1 || chain.Errors()[0] != raised {
+			t.Error("Expected raised error in chain found", err)
+		}
+	}
+	if cause := Simple.New(typed).Cause(); cause != typed {
+		t.Error("Unexpected cause", cause)
+	}
+}
+
 func TestRecoverPanic(t *testing.T) {
 	defer func() {
 		e := recover()
@@ -299,6 +322,9 @@ func DebugFaultFunc(failErr error) (err error) {
 }
 
 func TestDebugging(t *testing.T) {
+	if Release {
+		t.Skip("traces are not captured in release builds")
+	}
 	ptr := reflect.ValueOf(DebugFaultFunc).Pointer()
 	fn := runtime.FuncForPC(ptr)
 	name := fn.Name()
@@ -0,0 +1,61 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+/*
+Package faultdebug provides an HTTP endpoint for switching the tracing of
+registered fault checkers at runtime.
+
+	http.Handle("/debug/fault", faultdebug.Handler{})
+
+A GET request lists every registered checker along with its tracing state. A
+POST request with the form values pattern and tracing (on or off) switches all
+checkers whose names match the pattern.
+
+	curl -d pattern='mypkg/*' -d tracing=on http://localhost:8080/debug/fault
+*/
+package faultdebug
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/surullabs/fault"
+)
+
+// Handler serves the tracing state of registered checkers.
+type Handler struct{}
+
+func (Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+	case "POST":
+		pattern := r.FormValue("pattern")
+		var enabled bool
+		switch r.FormValue("tracing") {
+		case "on":
+			enabled = true
+		case "off":
+		default:
+			http.Error(w, "tracing must be on or off", http.StatusBadRequest)
+			return
+		}
+		if pattern == "" {
+			http.Error(w, "missing pattern", http.StatusBadRequest)
+			return
+		}
+		fault.SetTracing(pattern, enabled)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, name := range fault.Registered() {
+		state := "off"
+		if c := fault.Lookup(name); c != nil && c.Tracing() {
+			state = "on"
+		}
+		fmt.Fprintf(w, "%s %s\n", name, state)
+	}
+}
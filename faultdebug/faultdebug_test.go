@@ -0,0 +1,56 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package faultdebug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/surullabs/fault"
+)
+
+func serve(method string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/debug/fault", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	Handler{}.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestHandler(t *testing.T) {
+	if fault.Release {
+		t.Skip("tracing cannot be enabled in release builds")
+	}
+	a := fault.Register("faultdebug/a", fault.NewChecker().SetTracing(true))
+	fault.Register("faultdebug/b", fault.NewChecker().SetFaulter(fault.Simple))
+
+	for _, test := range []struct {
+		name   string
+		method string
+		form   url.Values
+		code   int
+		body   string
+	}{
+		{"list", "GET", nil, http.StatusOK, "faultdebug/a on\nfaultdebug/b off\n"},
+		{"off", "POST", url.Values{"pattern": {"faultdebug/*"}, "tracing": {"off"}}, http.StatusOK, "faultdebug/a off\nfaultdebug/b off\n"},
+		{"on", "POST", url.Values{"pattern": {"faultdebug/b"}, "tracing": {"on"}}, http.StatusOK, "faultdebug/a off\nfaultdebug/b on\n"},
+		{"bad state", "POST", url.Values{"pattern": {"faultdebug/b"}, "tracing": {"yes"}}, http.StatusBadRequest, ""},
+		{"no pattern", "POST", url.Values{"tracing": {"on"}}, http.StatusBadRequest, ""},
+		{"bad method", "DELETE", nil, http.StatusMethodNotAllowed, ""},
+	} {
+		t.Log(test.name)
+		recorder := serve(test.method, test.form)
+		if recorder.Code != test.code {
+			t.Error("Expected", test.code, "found", recorder.Code)
+		} else if test.body != "" && recorder.Body.String() != test.body {
+			t.Error("Unexpected body", recorder.Body.String())
+		}
+	}
+	if a.Tracing() {
+		t.Error("Tracing not switched")
+	}
+}
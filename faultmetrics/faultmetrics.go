@@ -0,0 +1,214 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+/*
+Package faultmetrics counts the faults raised by fault.Checker instances per
+call site and per code, and exposes the counts through expvar and the
+Prometheus text exposition format.
+
+	var check = fault.NewChecker()
+
+	func init() {
+		faultmetrics.Default.Watch(check)
+		faultmetrics.Default.Publish("faults")
+		http.Handle("/metrics/faults", faultmetrics.Default)
+	}
+
+Sites are taken from the start of the fault trace, so only checkers using a
+fault.DebugFaulter report precise sites. Faults without a trace are counted
+against the unknown site "?". Codes are assigned by Counters.Code, which
+defaults to fault.ExitCode.
+*/
+package faultmetrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/surullabs/fault"
+)
+
+// Site identifies the location at which a fault was raised.
+type Site struct {
+	Function string // Function is the fully qualified name of the raising function
+	Location string // Location is the file:line of the raising call
+}
+
+func (s Site) String() string { return fmt.Sprintf("%s (%s)", s.Function, s.Location) }
+
+// Counters holds fault counts per site and per code. It is safe for
+// concurrent use.
+type Counters struct {
+	// Code classifies the cause of a fault. It defaults to fault.ExitCode and
+	// must be set before the counters are used.
+	Code func(error) int
+
+	mu    sync.RWMutex
+	sites map[Site]*int64
+	codes map[int]*int64
+}
+
+// Default is the Counters instance used by most programs.
+var Default = New()
+
+// New returns an empty set of counters.
+func New() *Counters {
+	return &Counters{sites: make(map[Site]*int64), codes: make(map[int]*int64)}
+}
+
+// Watch registers a raise hook on check which counts all its faults.
+func (c *Counters) Watch(check *fault.Checker) { check.OnRaise(c.Raised) }
+
+// Raised increments the counters for the start site and the code of f.
+func (c *Counters) Raised(f fault.Fault) {
+	call := fault.StartSite(fault.GetTrace(f))
+	site := Site{Function: call.Name, Location: call.String()}
+	classify := c.Code
+	if classify == nil {
+		classify = fault.ExitCode
+	}
+	code := classify(f.Cause())
+
+	c.mu.RLock()
+	siteCount, siteFound := c.sites[site]
+	codeCount, codeFound := c.codes[code]
+	c.mu.RUnlock()
+	if !siteFound || !codeFound {
+		c.mu.Lock()
+		if siteCount, siteFound = c.sites[site]; !siteFound {
+			siteCount = new(int64)
+			c.sites[site] = siteCount
+		}
+		if codeCount, codeFound = c.codes[code]; !codeFound {
+			codeCount = new(int64)
+			c.codes[code] = codeCount
+		}
+		c.mu.Unlock()
+	}
+	atomic.AddInt64(siteCount, 1)
+	atomic.AddInt64(codeCount, 1)
+}
+
+// Snapshot returns the current count for every site.
+func (c *Counters) Snapshot() map[Site]int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot := make(map[Site]int64, len(c.sites))
+	for site, count := range c.sites {
+		snapshot[site] = atomic.LoadInt64(count)
+	}
+	return snapshot
+}
+
+// Codes returns the current count for every code.
+func (c *Counters) Codes() map[int]int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	codes := make(map[int]int64, len(c.codes))
+	for code, count := range c.codes {
+		codes[code] = atomic.LoadInt64(count)
+	}
+	return codes
+}
+
+// Reset clears all counts.
+func (c *Counters) Reset() {
+	c.mu.Lock()
+	c.sites = make(map[Site]*int64)
+	c.codes = make(map[int]*int64)
+	c.mu.Unlock()
+}
+
+// sorted returns the snapshot ordered by site.
+func (c *Counters) sorted() (sites []Site, counts []int64) {
+	snapshot := c.Snapshot()
+	for site := range snapshot {
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Function != sites[j].Function {
+			return sites[i].Function < sites[j].Function
+		}
+		return sites[i].Location < sites[j].Location
+	})
+	counts = make([]int64, len(sites))
+	for i, site := range sites {
+		counts[i] = snapshot[site]
+	}
+	return
+}
+
+// sortedCodes returns the code counts ordered by code.
+func (c *Counters) sortedCodes() (codes []int, counts []int64) {
+	snapshot := c.Codes()
+	for code := range snapshot {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	counts = make([]int64, len(codes))
+	for i, code := range codes {
+		counts[i] = snapshot[code]
+	}
+	return
+}
+
+// String implements expvar.Var. Counts are rendered as a JSON object with the
+// member "sites" keyed by Site.String() and the member "codes" keyed by code.
+func (c *Counters) String() string {
+	values := struct {
+		Sites map[string]int64 `json:"sites"`
+		Codes map[string]int64 `json:"codes"`
+	}{make(map[string]int64), make(map[string]int64)}
+	sites, counts := c.sorted()
+	for i, site := range sites {
+		values.Sites[site.String()] = counts[i]
+	}
+	codes, counts := c.sortedCodes()
+	for i, code := range codes {
+		values.Codes[strconv.Itoa(code)] = counts[i]
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// Publish publishes the counters through expvar under name. Like
+// expvar.Publish it panics if the name is already in use.
+func (c *Counters) Publish(name string) { expvar.Publish(name, c) }
+
+// WritePrometheus writes the counters in the Prometheus text exposition format
+// as the metrics fault_raised_total, labelled by site, and
+// fault_raised_by_code_total, labelled by code. The two are kept apart so that
+// summing either one gives the total number of faults.
+func (c *Counters) WritePrometheus(w io.Writer) {
+	sites, counts := c.sorted()
+	fmt.Fprint(w, "# HELP fault_raised_total Number of faults raised per site.\n")
+	fmt.Fprint(w, "# TYPE fault_raised_total counter\n")
+	for i, site := range sites {
+		fmt.Fprintf(w, "fault_raised_total{function=\"%s\",site=\"%s\"} %d\n",
+			escapeLabel(site.Function), escapeLabel(site.Location), counts[i])
+	}
+	codes, counts := c.sortedCodes()
+	fmt.Fprint(w, "# HELP fault_raised_by_code_total Number of faults raised per code.\n")
+	fmt.Fprint(w, "# TYPE fault_raised_by_code_total counter\n")
+	for i, code := range codes {
+		fmt.Fprintf(w, "fault_raised_by_code_total{code=\"%d\"} %d\n", code, counts[i])
+	}
+}
+
+// ServeHTTP serves the counters in the Prometheus text exposition format.
+func (c *Counters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WritePrometheus(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
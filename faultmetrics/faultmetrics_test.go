@@ -0,0 +1,121 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package faultmetrics
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/surullabs/fault"
+)
+
+func raiseFrom(check *fault.Checker, fail bool) (err error) {
+	defer check.Recover(&err)
+	check.True(!fail, "failed")
+	return
+}
+
+func TestCounters(t *testing.T) {
+	if fault.Release {
+		t.Skip("traces are not captured in release builds")
+	}
+	counters := New()
+	check := fault.NewChecker()
+	counters.Watch(check)
+
+	for _, fail := range []bool{true, false, true} {
+		raiseFrom(check, fail)
+	}
+
+	snapshot := counters.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatal("Expected one site found", snapshot)
+	}
+	for site, count := range snapshot {
+		if site.Function != "github.com/surullabs/fault/faultmetrics.raiseFrom" {
+			t.Error("Unexpected function", site.Function)
+		}
+		if !strings.HasPrefix(site.Location, "faultmetrics_test.go:") {
+			t.Error("Unexpected location", site.Location)
+		}
+		if count != 2 {
+			t.Error("Expected 2 faults found", count)
+		}
+	}
+
+	simple := fault.NewChecker().SetFaulter(fault.Simple)
+	counters.Watch(simple)
+	raiseFrom(simple, true)
+	if count := counters.Snapshot()[Site{"?", "?:-1"}]; count != 1 {
+		t.Error("Expected unknown site count found", count)
+	}
+	if codes := counters.Codes(); len(codes) != 1 || codes[fault.ExitFailure] != 3 {
+		t.Error("Unexpected code counts", codes)
+	}
+
+	counters.Reset()
+	if len(counters.Snapshot()) != 0 {
+		t.Error("Counters not reset")
+	}
+}
+
+func TestCode(t *testing.T) {
+	counters := New()
+	counters.Raised(fault.Simple.New(fault.WithExitCode(errors.New("bad config"), fault.ExitConfig)))
+	counters.Raised(fault.Simple.New(errors.New("failed")))
+	if codes := counters.Codes(); len(codes) != 2 || codes[fault.ExitConfig] != 1 || codes[fault.ExitFailure] != 1 {
+		t.Error("Unexpected default codes", codes)
+	}
+
+	counters = New()
+	counters.Code = func(err error) int { return len(err.Error()) }
+	counters.Raised(fault.Simple.New(errors.New("failed")))
+	if codes := counters.Codes(); codes[6] != 1 {
+		t.Error("Custom classifier not used", codes)
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	counters := New()
+	counters.Raised(fault.Simple.New(errors.New("failed")))
+	counters.Publish("faultmetrics_test")
+
+	var values struct {
+		Sites map[string]int64
+		Codes map[string]int64
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("faultmetrics_test").String()), &values); err != nil {
+		t.Fatal(err)
+	}
+	if values.Sites["? (?:-1)"] != 1 || values.Codes["1"] != 1 {
+		t.Error("Unexpected expvar values", values)
+	}
+}
+
+func TestPrometheus(t *testing.T) {
+	counters := New()
+	counters.sites[Site{`pkg."quoted"`, "a.go:1"}] = new(int64)
+	counters.Raised(fault.Simple.New(fault.WithExitCode(errors.New("failed"), fault.ExitIOErr)))
+
+	recorder := httptest.NewRecorder()
+	counters.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	expected := `# HELP fault_raised_total Number of faults raised per site.
+# TYPE fault_raised_total counter
+fault_raised_total{function="?",site="?:-1"} 1
+fault_raised_total{function="pkg.\"quoted\"",site="a.go:1"} 0
+# HELP fault_raised_by_code_total Number of faults raised per code.
+# TYPE fault_raised_by_code_total counter
+fault_raised_by_code_total{code="74"} 1
+`
+	if recorder.Body.String() != expected {
+		t.Error("Unexpected output", recorder.Body.String())
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") {
+		t.Error("Unexpected content type")
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+/*
+Package faultsql provides database/sql helpers built on package fault.
+
+WithTx runs a function inside a transaction which is committed if the
+function returns normally and rolled back otherwise.
+
+	err := faultsql.WithTx(ctx, check, db, func(tx *sql.Tx, check fault.FaultCheck) {
+		check.Return(tx.Exec("UPDATE accounts SET balance = balance - 10 WHERE id = 1"))
+		check.Return(tx.Exec("UPDATE accounts SET balance = balance + 10 WHERE id = 2"))
+	})
+*/
+package faultsql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/surullabs/fault"
+)
+
+// WithTx begins a transaction on db and calls body with it and check. The
+// transaction is committed if body returns normally. Otherwise it is rolled
+// back: if body faults, the fault is recovered using check and the returned
+// error also contains any error from the rollback. Non-fault panics and calls
+// to runtime.Goexit roll back the transaction before they continue.
+func WithTx(ctx context.Context, check fault.FaultCheck, db *sql.DB, body func(tx *sql.Tx, check fault.FaultCheck)) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		panicked := recover()
+		rollbackErr := tx.Rollback()
+		check.RecoverPanic(&err, panicked)
+		err = fault.Chain(err, rollbackErr)
+	}()
+	body(tx, check)
+	completed = true
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package faultsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/surullabs/fault"
+)
+
+// stubDriver is a database/sql driver which only supports transactions.
+type stubDriver struct {
+	beginErr    error
+	commitErr   error
+	rollbackErr error
+	commits     int
+	rollbacks   int
+}
+
+func (d *stubDriver) Open(name string) (driver.Conn, error) { return &stubConn{d}, nil }
+
+type stubConn struct{ d *stubDriver }
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *stubConn) Close() error { return nil }
+func (c *stubConn) Begin() (driver.Tx, error) {
+	if c.d.beginErr != nil {
+		return nil, c.d.beginErr
+	}
+	return &stubTx{c.d}, nil
+}
+
+type stubTx struct{ d *stubDriver }
+
+func (t *stubTx) Commit() error {
+	t.d.commits++
+	return t.d.commitErr
+}
+
+func (t *stubTx) Rollback() error {
+	t.d.rollbacks++
+	return t.d.rollbackErr
+}
+
+var stub = &stubDriver{}
+
+func init() { sql.Register("faultsql_stub", stub) }
+
+var check = fault.NewChecker()
+
+func TestWithTx(t *testing.T) {
+	db, err := sql.Open("faultsql_stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, test := range []struct {
+		name      string
+		driver    stubDriver
+		body      func(*sql.Tx, fault.FaultCheck)
+		err       string
+		commits   int
+		rollbacks int
+	}{
+		{
+			"success",
+			stubDriver{},
+			func(tx *sql.Tx, check fault.FaultCheck) {},
+			"", 1, 0,
+		},
+		{
+			"begin error",
+			stubDriver{beginErr: errors.New("begin failed")},
+			func(tx *sql.Tx, check fault.FaultCheck) { t.Error("Body called") },
+			"begin failed", 0, 0,
+		},
+		{
+			"commit error",
+			stubDriver{commitErr: errors.New("commit failed")},
+			func(tx *sql.Tx, check fault.FaultCheck) {},
+			"commit failed", 1, 0,
+		},
+		{
+			"fault",
+			stubDriver{},
+			func(tx *sql.Tx, check fault.FaultCheck) { check.True(false, "body failed") },
+			"body failed", 0, 1,
+		},
+		{
+			"fault rollback error",
+			stubDriver{rollbackErr: errors.New("rollback failed")},
+			func(tx *sql.Tx, check fault.FaultCheck) { check.True(false, "body failed") },
+			"body failed; rollback failed", 0, 1,
+		},
+	} {
+		t.Log(test.name)
+		*stub = test.driver
+		err := WithTx(context.Background(), check, db, test.body)
+		if err == nil && test.err != "" {
+			t.Error("Expected error", test.err, "not found")
+		} else if err != nil && !strings.HasSuffix(err.Error(), test.err) {
+			t.Error("Expected", test.err, "found", err.Error())
+		} else if err != nil && test.err == "" {
+			t.Error("Unexpected error", err)
+		}
+		if stub.commits != test.commits || stub.rollbacks != test.rollbacks {
+			t.Error("Expected", test.commits, "commits and", test.rollbacks, "rollbacks found",
+				stub.commits, stub.rollbacks)
+		}
+	}
+}
+
+func TestWithTxPanic(t *testing.T) {
+	db, err := sql.Open("faultsql_stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	*stub = stubDriver{}
+	defer func() {
+		if e := recover(); e == nil || e.(string) != "different panic" {
+			t.Error("Not recovered")
+		}
+		if stub.rollbacks != 1 || stub.commits != 0 {
+			t.Error("Transaction not rolled back")
+		}
+	}()
+	WithTx(context.Background(), check, db, func(tx *sql.Tx, check fault.FaultCheck) { panic("different panic") })
+}
+
+func TestWithTxChecker(t *testing.T) {
+	db, err := sql.Open("faultsql_stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	*stub = stubDriver{}
+	var raised, recovered int
+	hooked := fault.NewChecker().SetFaulter(fault.Simple)
+	hooked.OnRaise(func(fault.Fault) { raised++ })
+	hooked.OnRecover(func(error, fault.Call) { recovered++ })
+	err = WithTx(context.Background(), hooked, db, func(tx *sql.Tx, check fault.FaultCheck) {
+		check.True(false, "body failed")
+	})
+	if err == nil || raised != 1 || recovered != 1 || stub.rollbacks != 1 {
+		t.Error("Caller's checker not used", err, raised, recovered, stub.rollbacks)
+	}
+}
+
+func TestWithTxGoexit(t *testing.T) {
+	db, err := sql.Open("faultsql_stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	*stub = stubDriver{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		WithTx(context.Background(), check, db, func(tx *sql.Tx, check fault.FaultCheck) { runtime.Goexit() })
+	}()
+	wg.Wait()
+	if stub.rollbacks != 1 || stub.commits != 0 {
+		t.Error("Transaction not rolled back on Goexit", stub.commits, stub.rollbacks)
+	}
+}
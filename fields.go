@@ -0,0 +1,128 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Field is a named value attached to an error.
+type Field struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+func (f Field) String() string { return fmt.Sprintf("%s=%v", f.Key, f.Value) }
+
+// fieldsError attaches fields to an error. They are appended to its message.
+type fieldsError struct {
+	error
+	fields []Field
+}
+
+func (f *fieldsError) Unwrap() error { return f.error }
+
+func (f *fieldsError) Error() string {
+	parts := make([]string, len(f.fields))
+	for i, field := range f.fields {
+		parts[i] = field.String()
+	}
+	return fmt.Sprintf("%s [%s]", f.error.Error(), strings.Join(parts, " "))
+}
+
+// WithFields returns err with fields attached. Fields are included in the
+// error message and can be retrieved using Fields. err is returned unchanged
+// if it is nil or there are no fields.
+func WithFields(err error, fields ...Field) error {
+	if err == nil || len(fields) == 0 {
+		return err
+	}
+	return &fieldsError{error: err, fields: fields}
+}
+
+// Fields returns the fields attached to err, along with those reported by
+// errors in its chain which implement a Fields() []Field method, such as
+// *CommandError. For an ErrorChain the fields of all errors in the chain are
+// returned.
+func Fields(err error) (fields []Field) {
+	members := []error{err}
+	if chain, isChain := err.(*ErrorChain); isChain {
+		members = chain.Errors()
+	}
+	for _, member := range members {
+		for member != nil {
+			switch f := member.(type) {
+			case *fieldsError:
+				fields = append(fields, f.fields...)
+			case interface{ Fields() []Field }:
+				fields = append(fields, f.Fields()...)
+			}
+			member = errors.Unwrap(member)
+		}
+	}
+	return
+}
+
+// ContextExtractor returns the fields which should be attached to faults
+// raised under ctx.
+type ContextExtractor func(ctx context.Context) []Field
+
+var extractors struct {
+	sync.RWMutex
+	list []ContextExtractor
+}
+
+// RegisterContextExtractor adds fn to the extractors consulted by checkers
+// bound to a context using In or WithContext.
+//
+//	fault.RegisterContextExtractor(func(ctx context.Context) []fault.Field {
+//		if user, ok := ctx.Value(userKey{}).(string); ok {
+//			return []fault.Field{{"user", user}}
+//		}
+//		return nil
+//	})
+func RegisterContextExtractor(fn ContextExtractor) {
+	extractors.Lock()
+	extractors.list = append(extractors.list, fn)
+	extractors.Unlock()
+}
+
+func init() {
+	RegisterContextExtractor(func(ctx context.Context) []Field {
+		if id := RequestID(ctx); id != "" {
+			return []Field{{"request_id", id}}
+		}
+		return nil
+	})
+}
+
+// ContextFields returns the fields extracted from ctx by all registered
+// extractors.
+func ContextFields(ctx context.Context) (fields []Field) {
+	extractors.RLock()
+	defer extractors.RUnlock()
+	for _, extract := range extractors.list {
+		fields = append(fields, extract(ctx)...)
+	}
+	return
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying the request correlation id.
+// Faults raised by checkers bound to the context carry it as the request_id
+// field.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request correlation id carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
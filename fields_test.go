@@ -0,0 +1,96 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func init() {
+	RegisterContextExtractor(func(ctx context.Context) []Field {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []Field{{"tenant", tenant}}
+		}
+		return nil
+	})
+}
+
+func TestWithFields(t *testing.T) {
+	base := errors.New("failed")
+	if WithFields(nil, Field{"a", 1}) != nil || WithFields(base) != base {
+		t.Error("Fields added unnecessarily")
+	}
+	err := WithFields(base, Field{"a", 1}, Field{"b", "x"})
+	if err.Error() != "failed [a=1 b=x]" || !errors.Is(err, base) {
+		t.Error("Unexpected error", err)
+	}
+	fields := Fields(Chain(err, WithFields(errors.New("other"), Field{"c", true})))
+	if len(fields) != 3 || fields[2].Key != "c" {
+		t.Error("Unexpected fields", fields)
+	}
+	if Fields(base) != nil {
+		t.Error("Fields found on plain error")
+	}
+}
+
+func TestCheckIn(t *testing.T) {
+	ctx := ContextWithRequestID(context.Background(), "req-42")
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	if RequestID(ctx) != "req-42" || RequestID(context.Background()) != "" {
+		t.Error("Request id not stored")
+	}
+
+	for _, c := range []*Checker{NewChecker(), NewChecker().SetFaulter(Simple)} {
+		check := c.In(ctx)
+		err := func() (err error) {
+			defer check.Recover(&err)
+			check.Truef(false, "bad id %d", 7)
+			return
+		}()
+		if !strings.HasSuffix(err.Error(), "bad id 7 [request_id=req-42 tenant=acme]") {
+			t.Error("Unexpected error", err)
+		}
+		if !strings.HasPrefix(VerboseTrace(err), err.Error()) {
+			t.Error("Fields missing from verbose trace", VerboseTrace(err))
+		}
+		fields := Fields(err)
+		if len(fields) != 2 || fields[0].Value != "req-42" {
+			t.Error("Unexpected fields", fields)
+		}
+		if messageTemplate(err.(*ErrorChain).Errors()[0]) != "bad id %d" {
+			t.Error("Template lost")
+		}
+
+		report := NewReport(err)
+		if len(report.Errors) != 1 || len(report.Errors[0].Fields) != 2 {
+			t.Error("Fields missing from report")
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err := func() (err error) {
+		check := contextCheck.In(cancelled)
+		defer check.Recover(&err)
+		check.True(true, "not raised")
+		check.Error(errors.New("failed"))
+		return
+	}()
+	if !strings.HasSuffix(err.Error(), "failed [request_id=req-42 tenant=acme]") {
+		t.Error("In checked cancellation", err)
+	}
+	err = func() (err error) {
+		defer contextCheck.Recover(&err)
+		contextCheck.Context(cancelled)
+		return
+	}()
+	if !errors.Is(err, context.Canceled) || len(Fields(err)) != 2 {
+		t.Error("Context fault missing fields", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"strings"
+)
+
+// elidedName is the name of the marker call which replaces elided frames.
+const elidedName = "..."
+
+// Elided returns the number of frames replaced by c if it is the marker
+// inserted by a TraceFilter with MaxDepth set.
+func (c *Call) Elided() (n int, elided bool) {
+	if c.File == "" && c.Name == elidedName {
+		return c.Line, true
+	}
+	return 0, false
+}
+
+// Package returns the import path of the package containing the call or an
+// empty string if it is unknown.
+func (c *Call) Package() string {
+	name := c.Name
+	start := strings.LastIndex(name, "/") + 1
+	if dot := strings.Index(name[start:], "."); dot >= 0 {
+		return name[:start+dot]
+	}
+	return ""
+}
+
+// CallFilter is a predicate which returns true for calls that should be
+// dropped from a trace.
+type CallFilter func(Call) bool
+
+var (
+	// DropRuntime drops calls in the runtime package.
+	DropRuntime CallFilter = func(c Call) bool { return c.Package() == "runtime" }
+
+	// DropStdlib drops calls in the standard library. A package is assumed to
+	// be part of the standard library if the first element of its import
+	// path contains no dot.
+	DropStdlib CallFilter = func(c Call) bool {
+		pkg := c.Package()
+		if pkg == "" || pkg == "main" {
+			return false
+		}
+		return !strings.Contains(strings.SplitN(pkg, "/", 2)[0], ".")
+	}
+
+	// DropTesting drops calls in the testing package and its subpackages.
+	DropTesting CallFilter = func(c Call) bool {
+		pkg := c.Package()
+		return pkg == "testing" || strings.HasPrefix(pkg, "testing/")
+	}
+
+	// DropVendored drops calls in vendored packages.
+	DropVendored CallFilter = func(c Call) bool {
+		pkg := c.Package()
+		return strings.HasPrefix(pkg, "vendor/") || strings.Contains(pkg, "/vendor/")
+	}
+)
+
+// DropModule returns a filter which drops calls in the module or package with
+// the given import path and all packages below it.
+func DropModule(path string) CallFilter {
+	return func(c Call) bool {
+		pkg := c.Package()
+		return pkg == path || strings.HasPrefix(pkg, path+"/")
+	}
+}
+
+// TraceFilter removes noise from traces.
+//
+//	var check = fault.NewChecker().SetFaulter(fault.DebugFaulter{
+//		Filter: &fault.TraceFilter{
+//			Drop:     []fault.CallFilter{fault.DropRuntime, fault.DropTesting},
+//			MaxDepth: 10,
+//		},
+//	})
+type TraceFilter struct {
+	// Drop holds the filters applied to each call. Calls for which any
+	// filter returns true are removed.
+	Drop []CallFilter
+	// MaxDepth limits the number of calls kept in a trace if it is greater
+	// than zero. Any remaining calls are replaced by a single marker call
+	// reporting how many frames were elided.
+	MaxDepth int
+}
+
+// Apply returns the calls in trace which pass the filter. A nil filter returns
+// trace unchanged.
+func (f *TraceFilter) Apply(trace []Call) []Call {
+	if f == nil {
+		return trace
+	}
+	filtered := make([]Call, 0, len(trace))
+	for _, call := range trace {
+		if !f.drop(call) {
+			filtered = append(filtered, call)
+		}
+	}
+	if f.MaxDepth > 0 && len(filtered) > f.MaxDepth {
+		elided := len(filtered) - f.MaxDepth
+		filtered = append(filtered[:f.MaxDepth], Call{Name: elidedName, Line: elided})
+	}
+	return filtered
+}
+
+func (f *TraceFilter) drop(call Call) bool {
+	for _, drop := range f.Drop {
+		if drop(call) {
+			return true
+		}
+	}
+	return false
+}
+
+// Traced works like the package level Traced, applying the filter to the
+// captured trace.
+func (f *TraceFilter) Traced(err error) error { return traced(err, f) }
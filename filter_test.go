@@ -0,0 +1,132 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCallPackage(t *testing.T) {
+	for name, pkg := range map[string]string{
+		"github.com/surullabs/fault.(*Checker).True": "github.com/surullabs/fault",
+		"runtime.goexit":         "runtime",
+		"net/http.(*conn).serve": "net/http",
+		"main.main.func1":        "main",
+		"?":                      "",
+	} {
+		if found := (&Call{Name: name}).Package(); found != pkg {
+			t.Error("Expected package", pkg, "for", name, "found", found)
+		}
+	}
+}
+
+func TestCallFilters(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		filter CallFilter
+		call   string
+		drop   bool
+	}{
+		{"runtime", DropRuntime, "runtime.goexit", true},
+		{"runtime other", DropRuntime, "runtime/debug.Stack", false},
+		{"stdlib", DropStdlib, "net/http.(*conn).serve", true},
+		{"stdlib testing", DropStdlib, "testing.tRunner", true},
+		{"stdlib main", DropStdlib, "main.main", false},
+		{"stdlib external", DropStdlib, "github.com/a/b.F", false},
+		{"testing", DropTesting, "testing.tRunner", true},
+		{"testing other", DropTesting, "github.com/a/testing.F", false},
+		{"vendored", DropVendored, "github.com/a/b/vendor/github.com/c/d.F", true},
+		{"vendored std", DropVendored, "vendor/golang.org/x/net/http2.F", true},
+		{"not vendored", DropVendored, "github.com/a/vendors.F", false},
+		{"module", DropModule("github.com/a/b"), "github.com/a/b.F", true},
+		{"module sub", DropModule("github.com/a/b"), "github.com/a/b/c.(*T).F", true},
+		{"module prefix", DropModule("github.com/a/b"), "github.com/a/bc.F", false},
+	} {
+		if test.filter(Call{Name: test.call}) != test.drop {
+			t.Error(test.name, "unexpected result for", test.call)
+		}
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	trace := []Call{
+		{"a.go", 1, "github.com/a/b.F"},
+		{"b.go", 2, "github.com/a/b.G"},
+		{"c.go", 3, "testing.tRunner"},
+		{"d.go", 4, "github.com/a/b.H"},
+		{"e.go", 5, "runtime.goexit"},
+	}
+	for _, test := range []struct {
+		name     string
+		filter   *TraceFilter
+		expected string
+	}{
+		{"nil", nil, "a.go:1 b.go:2 c.go:3 d.go:4 e.go:5"},
+		{"drop", &TraceFilter{Drop: []CallFilter{DropRuntime, DropTesting}}, "a.go:1 b.go:2 d.go:4"},
+		{"user", &TraceFilter{Drop: []CallFilter{func(c Call) bool { return c.Line%2 == 0 }}}, "a.go:1 c.go:3 e.go:5"},
+		{"depth", &TraceFilter{MaxDepth: 2}, "a.go:1 b.go:2 ... 3 frames elided"},
+		{"depth drop", &TraceFilter{Drop: []CallFilter{DropStdlib}, MaxDepth: 2}, "a.go:1 b.go:2 ... 1 frames elided"},
+		{"depth unused", &TraceFilter{MaxDepth: 5}, "a.go:1 b.go:2 c.go:3 d.go:4 e.go:5"},
+	} {
+		filtered := test.filter.Apply(append([]Call{}, trace...))
+		parts := make([]string, len(filtered))
+		for i := range filtered {
+			parts[i] = filtered[i].String()
+		}
+		if found := strings.Join(parts, " "); found != test.expected {
+			t.Error(test.name, "expected", test.expected, "found", found)
+		}
+	}
+}
+
+func TestFilteredTrace(t *testing.T) {
+	if Release {
+		t.Skip("traces are not captured in release builds")
+	}
+	filter := &TraceFilter{Drop: []CallFilter{DropRuntime, DropTesting}}
+	err := filter.Traced(errors.New("err"))
+	trace := GetTrace(err)
+	if len(trace) != 1 || trace[0].Name != "github.com/surullabs/fault.TestFilteredTrace" {
+		t.Error("Unexpected trace", trace)
+	}
+
+	check := NewChecker().SetFaulter(DebugFaulter{Filter: &TraceFilter{MaxDepth: 1}})
+	err = func() (err error) {
+		defer check.Recover(&err)
+		check.True(false, "failed")
+		return
+	}()
+	trace = GetTrace(err)
+	if len(trace) != 2 || !strings.HasPrefix(trace[0].Name, "github.com/surullabs/fault.TestFilteredTrace") {
+		t.Error("Unexpected trace", trace)
+	} else if _, elided := trace[1].Elided(); !elided {
+		t.Error("Missing elided marker", trace)
+	}
+}
+
+func TestEmptyFilteredTrace(t *testing.T) {
+	filter := &TraceFilter{Drop: []CallFilter{func(Call) bool { return true }}}
+	check := NewChecker().SetFaulter(DebugFaulter{Filter: filter})
+	faulted := func() (err error) {
+		defer check.Recover(&err)
+		check.True(false, "failed")
+		return
+	}()
+	for _, err := range []error{filter.Traced(errors.New("failed")), faulted} {
+		if len(GetTrace(err)) != 0 {
+			t.Error("Unexpected trace", GetTrace(err))
+		}
+		if trace := VerboseTrace(err); !strings.HasSuffix(trace, "failed") {
+			t.Error("Unexpected verbose trace", trace)
+		}
+		if site := StartSite(GetTrace(err)); site.Name != "?" {
+			t.Error("Unexpected start site", site)
+		}
+		QuickfixList(err)
+		Fingerprint(err)
+		NewReport(err).Text()
+	}
+}
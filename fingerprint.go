@@ -0,0 +1,84 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// templateError records the format string used to create an error.
+type templateError struct {
+	error
+	format string
+}
+
+func (t *templateError) Unwrap() error { return t.error }
+
+var errorStringType = reflect.TypeOf(errors.New(""))
+
+// Fingerprint returns a stable identifier for err which groups the same
+// failure across builds and deployments. It is computed from the function
+// names in the trace of err and the message template of the error. Line
+// numbers and formatted arguments are not included.
+//
+// The template is the format string passed to Truef or the message passed to
+// True. Errors created using errors.New use their message and all other errors
+// use their type. For an ErrorChain only the first error is used.
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+	if chain, isChain := err.(*ErrorChain); isChain && len(chain.Errors()) > 0 {
+		err = chain.Errors()[0]
+	}
+
+	hash := sha256.New()
+	for _, call := range GetTrace(err) {
+		if _, elided := call.Elided(); !elided {
+			fmt.Fprintln(hash, call.Name)
+		}
+	}
+	fmt.Fprintln(hash, messageTemplate(err))
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+func messageTemplate(err error) string {
+	switch f := err.(type) {
+	case *debugFault:
+		err = f.err
+	case *errorFault:
+		err = f.err
+	}
+	err = stripAnnotations(err)
+	var t *templateError
+	if errors.As(err, &t) {
+		return t.format
+	}
+	if reflect.TypeOf(err) == errorStringType {
+		return err.Error()
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// stripAnnotations removes the fields and public message attached to err.
+func stripAnnotations(err error) error {
+	for {
+		switch a := err.(type) {
+		case *fieldsError:
+			err = a.error
+		case *publicError:
+			err = a.error
+		case *redactedError:
+			err = a.error
+		case *idError:
+			err = a.error
+		default:
+			return err
+		}
+	}
+}
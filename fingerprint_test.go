@@ -0,0 +1,75 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func fingerprintOf(check *Checker, fn func(*Checker)) string {
+	return Fingerprint(func() (err error) {
+		defer check.Recover(&err)
+		fn(check)
+		return
+	}())
+}
+
+func raiseTruef(check *Checker, id int) { check.Truef(false, "bad id %d", id) }
+
+func raiseTruefOther(check *Checker, id int) { check.Truef(false, "bad id %d", id) }
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint(nil) != "" {
+		t.Error("Fingerprint for nil error")
+	}
+
+	for _, check := range []*Checker{NewChecker(), NewChecker().SetFaulter(Simple)} {
+		var prints []string
+		for id := 0; id < 2; id++ {
+			prints = append(prints, fingerprintOf(check, func(c *Checker) { raiseTruef(c, id) }))
+		}
+		a, b := prints[0], prints[1]
+		if a == "" || a != b {
+			t.Error("Fingerprint depends on arguments", a, b)
+		}
+		if c := fingerprintOf(check, func(c *Checker) { c.Truef(false, "other %d", 1) }); c == a {
+			t.Error("Fingerprint ignores template")
+		}
+
+		d := fingerprintOf(check, func(c *Checker) { c.True(false, "msg") })
+		e := fingerprintOf(check, func(c *Checker) { c.True(false, "msg") })
+		if f := fingerprintOf(check, func(c *Checker) { c.True(false, "other msg") }); f == d {
+			t.Error("Fingerprint ignores message")
+		}
+		if check.Tracing() && d == e {
+			t.Error("Fingerprint ignores functions")
+		} else if !check.Tracing() && d != e {
+			t.Error("Fingerprint differs without trace")
+		}
+
+		prints = nil
+		for _, name := range []string{"/nonexistent/a", "/nonexistent/b"} {
+			_, pathErr := os.Open(name)
+			prints = append(prints, fingerprintOf(check, func(c *Checker) { c.Error(pathErr) }))
+		}
+		if prints[0] != prints[1] {
+			t.Error("Fingerprint depends on error contents")
+		}
+	}
+
+	if Release {
+		return
+	}
+	check := NewChecker()
+	a := fingerprintOf(check, func(c *Checker) { raiseTruef(c, 1) })
+	b := fingerprintOf(check, func(c *Checker) { raiseTruefOther(c, 1) })
+	if a == b {
+		t.Error("Fingerprint ignores trace")
+	}
+	if Fingerprint(Chain(errors.New("a"), errors.New("b"))) != Fingerprint(errors.New("a")) {
+		t.Error("Fingerprint of chain does not use first error")
+	}
+}
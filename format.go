@@ -0,0 +1,144 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// CallFormatter renders calls using paths relative to the root of the module
+// containing them instead of just the base name of the file. Calls in the main
+// module can optionally be linked to a source host.
+//
+//	formatter := fault.NewCallFormatter()
+//	formatter.LinkTemplate = "https://github.com/me/app/blob/{revision}/{path}#L{line}"
+//	log.Print(formatter.VerboseTrace(err))
+type CallFormatter struct {
+	// Module is the path of the main module.
+	Module string
+	// MainPackage is the import path of the main package. It is used to
+	// resolve calls in package main, whose function names do not include
+	// the import path.
+	MainPackage string
+	// Revision is the VCS revision the binary was built from.
+	Revision string
+	// LinkTemplate, if set, is used to add a link to calls within the main
+	// module. The placeholders {path}, {line} and {revision} are replaced
+	// by the module relative path, the line number and Revision.
+	LinkTemplate string
+}
+
+// NewCallFormatter returns a formatter populated using the build information
+// embedded in the running binary.
+func NewCallFormatter() *CallFormatter {
+	f := &CallFormatter{}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return f
+	}
+	f.Module = info.Main.Path
+	f.MainPackage = info.Path
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			f.Revision = setting.Value
+		}
+	}
+	return f
+}
+
+// Path returns the path of the file containing c. Files in the main module are
+// relative to the module root while files in other packages are prefixed with
+// the package import path. The base name is returned if the package is
+// unknown.
+func (f *CallFormatter) Path(c Call) string {
+	base := filepath.Base(c.File)
+	pkg := c.Package()
+	if pkg == "main" {
+		pkg = f.MainPackage
+	}
+	if pkg == "" {
+		return base
+	}
+	if rel, inModule := f.relative(pkg); inModule {
+		return path.Join(rel, base)
+	}
+	return path.Join(pkg, base)
+}
+
+// relative returns the directory of pkg relative to the main module root.
+func (f *CallFormatter) relative(pkg string) (rel string, inModule bool) {
+	if f.Module == "" {
+		return "", false
+	}
+	if pkg == f.Module {
+		return "", true
+	}
+	if strings.HasPrefix(pkg, f.Module+"/") {
+		return pkg[len(f.Module)+1:], true
+	}
+	return "", false
+}
+
+// Format renders c as path:line followed by a link if the call is in the main
+// module and LinkTemplate is set.
+func (f *CallFormatter) Format(c Call) string {
+	if _, elided := c.Elided(); elided {
+		return c.String()
+	}
+	p := f.Path(c)
+	out := fmt.Sprintf("%s:%d", p, c.Line)
+	if f.LinkTemplate == "" {
+		return out
+	}
+	pkg := c.Package()
+	if pkg == "main" {
+		pkg = f.MainPackage
+	}
+	if _, inModule := f.relative(pkg); !inModule {
+		return out
+	}
+	link := strings.NewReplacer(
+		"{path}", p,
+		"{line}", strconv.Itoa(c.Line),
+		"{revision}", f.Revision,
+	).Replace(f.LinkTemplate)
+	return out + " " + link
+}
+
+// VerboseTrace works like the package level VerboseTrace, rendering calls
+// using Format.
+func (f *CallFormatter) VerboseTrace(err error) string {
+	return verboseTrace(err, f.Format)
+}
+
+// Quickfix returns c as an editor friendly file:line: location using the
+// absolute file path.
+func (c *Call) Quickfix() string { return fmt.Sprintf("%s:%d:", c.File, c.Line) }
+
+// QuickfixList renders the trace of err in the format used by editor quickfix
+// lists. The first line holds the error message and each following line the
+// function name of a call.
+func QuickfixList(err error) string {
+	if chain, isChain := err.(*ErrorChain); isChain && len(chain.Errors()) == 1 {
+		err = chain.Errors()[0]
+	}
+	trace := GetTrace(err)
+	lines := make([]string, 0, len(trace))
+	for i := range trace {
+		if _, elided := trace[i].Elided(); elided {
+			continue
+		}
+		msg := trace[i].Name
+		if len(lines) == 0 {
+			msg = err.(*debugFault).err.Error()
+		}
+		lines = append(lines, trace[i].Quickfix()+" "+msg)
+	}
+	return Redact(strings.Join(lines, "\n"))
+}
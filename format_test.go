@@ -0,0 +1,62 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCallFormatter(t *testing.T) {
+	f := &CallFormatter{
+		Module:       "github.com/me/app",
+		MainPackage:  "github.com/me/app/cmd/tool",
+		Revision:     "abc123",
+		LinkTemplate: "https://host/{revision}/{path}#L{line}",
+	}
+	for _, test := range []struct {
+		call     Call
+		expected string
+	}{
+		{Call{"/src/app/util/util.go", 12, "github.com/me/app/util.F"}, "util/util.go:12 https://host/abc123/util/util.go#L12"},
+		{Call{"/src/app/app.go", 3, "github.com/me/app.(*T).M"}, "app.go:3 https://host/abc123/app.go#L3"},
+		{Call{"/src/app/cmd/tool/main.go", 7, "main.main"}, "cmd/tool/main.go:7 https://host/abc123/cmd/tool/main.go#L7"},
+		{Call{"/go/pkg/mod/github.com/dep/x@v1/x.go", 5, "github.com/dep/x.F"}, "github.com/dep/x/x.go:5"},
+		{Call{"/usr/go/src/net/http/server.go", 9, "net/http.(*conn).serve"}, "net/http/server.go:9"},
+		{Call{"/src/other/util.go", 1, "?"}, "util.go:1"},
+		{Call{"", 4, elidedName}, "... 4 frames elided"},
+	} {
+		if found := f.Format(test.call); found != test.expected {
+			t.Error("Expected", test.expected, "found", found)
+		}
+	}
+
+	f.LinkTemplate = ""
+	if found := f.Format(Call{"/src/app/util/util.go", 12, "github.com/me/app/util.F"}); found != "util/util.go:12" {
+		t.Error("Unexpected format without link", found)
+	}
+	if found := (&CallFormatter{}).Path(Call{"/src/app/util/util.go", 12, "github.com/me/app/util.F"}); found != "github.com/me/app/util/util.go" {
+		t.Error("Unexpected path without module", found)
+	}
+}
+
+func TestQuickfix(t *testing.T) {
+	err := &debugFault{err: errors.New("failed"), trace: []Call{
+		{"/src/a.go", 1, "pkg.A"},
+		{"/src/b.go", 2, "pkg.B"},
+		{"", 3, elidedName},
+	}}
+	expected := "/src/a.go:1: failed\n/src/b.go:2: pkg.B"
+	if found := QuickfixList(Chain(err)); found != expected {
+		t.Error("Expected", expected, "found", found)
+	}
+	if QuickfixList(errors.New("untraced")) != "" {
+		t.Error("Quickfix list for untraced error")
+	}
+
+	f := &CallFormatter{}
+	if found := f.VerboseTrace(err); found != "a.go:1: failed\npkg/b.go:2\n... 3 frames elided" {
+		t.Error("Unexpected verbose trace", found)
+	}
+}
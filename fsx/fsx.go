@@ -0,0 +1,136 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+/*
+Package fsx wraps common filesystem operations so that they raise faults
+instead of returning errors. Every fault carries the operation and path as the
+fields op and path, with Rename and Copy also adding the destination as
+newpath.
+
+	var check = fault.NewChecker()
+
+	func update(path string) (err error) {
+		defer check.Recover(&err)
+		files := fsx.New(check)
+		data := files.ReadFile(path)
+		files.WriteFile(path, transform(data), 0644)
+		return
+	}
+
+Writes are atomic: data is written to a temporary file in the same directory
+which is then renamed over the destination. The temporary file is removed if
+the write fails.
+*/
+package fsx
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/surullabs/fault"
+)
+
+func init() { fault.HelperType(&FS{}) }
+
+// FS performs filesystem operations, raising faults using its checker.
+type FS struct {
+	check fault.FaultCheck
+}
+
+// New returns an FS which raises faults using check.
+func New(check fault.FaultCheck) *FS { return &FS{check: check} }
+
+// fail raises a fault for err, if it is not nil, with op, path and any extra
+// fields attached.
+func (f *FS) fail(err error, op, path string, extra ...fault.Field) {
+	if err != nil {
+		fields := append([]fault.Field{{Key: "op", Value: op}, {Key: "path", Value: path}}, extra...)
+		f.check.Error(fault.WithFields(err, fields...))
+	}
+}
+
+// ReadFile returns the contents of the file at path.
+func (f *FS) ReadFile(path string) []byte {
+	data, err := os.ReadFile(path)
+	f.fail(err, "read", path)
+	return data
+}
+
+// WriteFile atomically replaces the file at path with data.
+func (f *FS) WriteFile(path string, data []byte, perm os.FileMode) {
+	f.fail(writeAtomic(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}), "write", path)
+}
+
+// MkdirAll creates the directory at path along with any missing parents.
+func (f *FS) MkdirAll(path string, perm os.FileMode) {
+	f.fail(os.MkdirAll(path, perm), "mkdir", path)
+}
+
+// Rename renames oldpath to newpath.
+func (f *FS) Rename(oldpath, newpath string) {
+	f.fail(os.Rename(oldpath, newpath), "rename", oldpath, fault.Field{Key: "newpath", Value: newpath})
+}
+
+// RemoveAll removes the file or directory at path along with its contents. It
+// does nothing if path does not exist.
+func (f *FS) RemoveAll(path string) {
+	f.fail(os.RemoveAll(path), "removeall", path)
+}
+
+// Walk calls fn for each file and directory in the tree rooted at root, in
+// lexical order. Faults raised by fn propagate to the caller.
+func (f *FS) Walk(root string, fn func(path string, d fs.DirEntry)) {
+	f.fail(filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		f.fail(err, "walk", path)
+		fn(path, d)
+		return nil
+	}), "walk", root)
+}
+
+// Copy atomically replaces the file at dst with a copy of the file at src,
+// keeping the permissions of src.
+func (f *FS) Copy(dst, src string) {
+	newpath := fault.Field{Key: "newpath", Value: dst}
+	in, err := os.Open(src)
+	f.fail(err, "copy", src, newpath)
+	defer in.Close()
+	info, err := in.Stat()
+	f.fail(err, "copy", src, newpath)
+	f.fail(writeAtomic(dst, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	}), "copy", src, newpath)
+}
+
+// writeAtomic writes the file at path using a temporary file which is renamed
+// into place once write succeeds and removed otherwise.
+func writeAtomic(path string, perm os.FileMode, write func(io.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,135 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fsx
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/surullabs/fault"
+)
+
+var check = fault.NewChecker().SetFaulter(fault.Simple)
+
+func run(fn func()) (err error) {
+	defer check.Recover(&err)
+	fn()
+	return
+}
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	files := New(check)
+
+	err := run(func() {
+		sub := filepath.Join(dir, "a", "b")
+		files.MkdirAll(sub, 0755)
+		files.WriteFile(filepath.Join(sub, "f.txt"), []byte("one"), 0600)
+		files.WriteFile(filepath.Join(sub, "f.txt"), []byte("two"), 0640)
+		if string(files.ReadFile(filepath.Join(sub, "f.txt"))) != "two" {
+			t.Error("File not replaced")
+		}
+		files.Copy(filepath.Join(dir, "copy.txt"), filepath.Join(sub, "f.txt"))
+		files.Rename(filepath.Join(dir, "copy.txt"), filepath.Join(dir, "moved.txt"))
+
+		var walked []string
+		files.Walk(dir, func(path string, d fs.DirEntry) {
+			rel, _ := filepath.Rel(dir, path)
+			walked = append(walked, rel)
+		})
+		if strings.Join(walked, ",") != ".,a,a/b,a/b/f.txt,moved.txt" {
+			t.Error("Unexpected walk", walked)
+		}
+		files.RemoveAll(filepath.Join(dir, "a"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "moved.txt"))
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Error("Copy did not keep permissions", info, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Error("Unexpected files left behind", entries)
+	}
+}
+
+func TestFSFaults(t *testing.T) {
+	dir := t.TempDir()
+	files := New(check)
+	missing := filepath.Join(dir, "missing")
+
+	for _, test := range []struct {
+		op string
+		fn func()
+	}{
+		{"read", func() { files.ReadFile(missing) }},
+		{"write", func() { files.WriteFile(filepath.Join(missing, "f"), nil, 0644) }},
+		{"rename", func() { files.Rename(missing, filepath.Join(dir, "other")) }},
+		{"copy", func() { files.Copy(filepath.Join(dir, "other"), missing) }},
+		{"walk", func() { files.Walk(missing, func(string, fs.DirEntry) {}) }},
+	} {
+		err := run(test.fn)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Error(test.op, "unexpected error", err)
+			continue
+		}
+		fields := fault.Fields(err)
+		if len(fields) < 2 || fields[0].Value != test.op || !strings.HasPrefix(fields[1].Value.(string), missing) {
+			t.Error(test.op, "unexpected fields", fields)
+		}
+	}
+
+	existing := filepath.Join(dir, "existing")
+	os.WriteFile(existing, nil, 0644)
+	for _, test := range []struct {
+		op       string
+		fn       func()
+		src, dst string
+	}{
+		{"rename", func() { files.Rename(existing, filepath.Join(missing, "moved")) }, existing, filepath.Join(missing, "moved")},
+		{"copy", func() { files.Copy(filepath.Join(missing, "copied"), existing) }, existing, filepath.Join(missing, "copied")},
+		{"copy", func() { files.Copy(filepath.Join(dir, "copied"), dir) }, dir, filepath.Join(dir, "copied")},
+	} {
+		fields := fault.Fields(run(test.fn))
+		if len(fields) != 3 || fields[0].Value != test.op || fields[1].Value != test.src ||
+			fields[2].Key != "newpath" || fields[2].Value != test.dst {
+			t.Error(test.op, "paths not reported", fields)
+		}
+	}
+
+	err := run(func() {
+		files.Walk(dir, func(path string, d fs.DirEntry) { check.True(false, "stop") })
+	})
+	if err == nil || err.Error() != "stop" {
+		t.Error("Fault in walk function not propagated", err)
+	}
+}
+
+func TestWriteAtomicCleanup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.txt")
+	os.WriteFile(path, []byte("original"), 0644)
+
+	failed := errors.New("write failed")
+	err := writeAtomic(path, 0644, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failed
+	})
+	if err != failed {
+		t.Error("Unexpected error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" {
+		t.Error("Original file modified", string(data))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Error("Temporary file not removed", entries)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	// helperFuncs holds the names of functions marked using Helper.
+	helperFuncs sync.Map
+	// helperTypes holds the method name prefixes of types registered using
+	// HelperType.
+	helperTypes sync.Map
+)
+
+func init() {
+	HelperType(&Checker{})
+	HelperType(&Rollback{})
+	HelperType(&ContextChecker{})
+	HelperType(&CheckedWriter{})
+	HelperType(&CheckedReader{})
+}
+
+// Helper marks the calling function as a helper, in the same way as
+// testing.T.Helper. Helpers are skipped when determining the start of a trace,
+// which allows FaultCheck implementations wrapping a Checker to report the
+// sites of their callers.
+//
+//	func (m *MyCheck) NotEmpty(s string) {
+//		fault.Helper()
+//		m.checker.True(s != "", "empty string")
+//	}
+func Helper() {
+	var pc [1]uintptr
+	if runtime.Callers(2, pc[:]) == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pc[:]).Next()
+	if frame.Function != "" {
+		helperFuncs.Store(frame.Function, struct{}{})
+	}
+}
+
+// HelperType marks all methods of the type of i as helpers. Methods of both
+// the type and a pointer to it are marked, along with any closures within
+// them.
+//
+//	fault.HelperType(&MyCheck{})
+func HelperType(i interface{}) {
+	t := reflect.TypeOf(i)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	helperTypes.Store(t.PkgPath()+".(*"+t.Name()+").", struct{}{})
+	helperTypes.Store(t.PkgPath()+"."+t.Name()+".", struct{}{})
+}
+
+func isHelper(name string) (helper bool) {
+	if _, helper = helperFuncs.Load(name); helper {
+		return
+	}
+	helperTypes.Range(func(prefix, _ interface{}) bool {
+		helper = strings.HasPrefix(name, prefix.(string))
+		return !helper
+	})
+	return
+}
+
+// Callers returns the stack of the calling goroutine, starting skip frames
+// above the caller of Callers. Leading frames which belong to helpers are
+// dropped so that the first call is the site a fault should be attributed
+// to. Release builds always return an empty stack.
+func Callers(skip int) (trace []Call) {
+	trace = make([]Call, 0)
+	if Release {
+		return
+	}
+
+	pcs := make([]uintptr, 32)
+	for {
+		n := runtime.Callers(skip+2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+
+	frames := runtime.CallersFrames(pcs)
+	for more := len(pcs) > 0; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		call := Call{File: frame.File, Line: frame.Line, Name: frame.Function}
+		if call.Name == "" {
+			call.Name = "?"
+		}
+		if len(trace) == 0 && isHelper(call.Name) {
+			continue
+		}
+		trace = append(trace, call)
+	}
+	return
+}
@@ -0,0 +1,99 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"testing"
+)
+
+// wrapCheck is a FaultCheck implementation wrapping a Checker.
+type wrapCheck struct {
+	*Checker
+}
+
+func (w wrapCheck) NotEmpty(s string) {
+	Helper()
+	w.True(s != "", "empty string")
+}
+
+func (w wrapCheck) unmarked(s string) {
+	w.True(s != "", "empty string")
+}
+
+type typedCheck struct {
+	*Checker
+}
+
+func (t *typedCheck) NotEmpty(s string) { t.True(s != "", "empty string") }
+
+func init() { HelperType(&typedCheck{}) }
+
+func startName(fn func()) string {
+	var err error
+	func() {
+		defer NewChecker().Recover(&err)
+		fn()
+	}()
+	return StartSite(GetTrace(err)).Name
+}
+
+func callHelper() { wrapCheck{NewChecker().SetTracing(true)}.NotEmpty("") }
+
+func callUnmarked() { wrapCheck{NewChecker().SetTracing(true)}.unmarked("") }
+
+func callHelperType() { (&typedCheck{NewChecker().SetTracing(true)}).NotEmpty("") }
+
+func callChecker() { NewChecker().SetTracing(true).Truef(false, "failed") }
+
+func TestHelper(t *testing.T) {
+	if Release {
+		t.Skip("traces are not captured in release builds")
+	}
+	for _, test := range []struct {
+		name  string
+		fn    func()
+		start string
+	}{
+		{"helper", callHelper, "github.com/surullabs/fault.callHelper"},
+		{"unmarked", callUnmarked, "github.com/surullabs/fault.wrapCheck.unmarked"},
+		{"helper type", callHelperType, "github.com/surullabs/fault.callHelperType"},
+		{"checker", callChecker, "github.com/surullabs/fault.callChecker"},
+	} {
+		if start := startName(test.fn); start != test.start {
+			t.Error(test.name, "expected", test.start, "found", start)
+		}
+	}
+}
+
+func TestIsHelper(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		helper bool
+	}{
+		{"github.com/surullabs/fault.(*Checker).True", true},
+		{"github.com/surullabs/fault.(*Checker).True.func1", true},
+		{"github.com/surullabs/fault.(*CheckerX).True", false},
+		{"github.com/surullabs/faultx.(*Checker).True", false},
+		{"github.com/surullabs/fault.Checker.True", true},
+		{"github.com/surullabs/fault.Helper", false},
+	} {
+		if isHelper(test.name) != test.helper {
+			t.Error("Unexpected helper state for", test.name)
+		}
+	}
+}
+
+func TestTraced(t *testing.T) {
+	if Release {
+		t.Skip("traces are not captured in release builds")
+	}
+	err := Traced(errors.New("err"))
+	if name := StartSite(GetTrace(err)).Name; name != "github.com/surullabs/fault.TestTraced" {
+		t.Error("Unexpected trace start", name)
+	}
+	if Traced(err) != err {
+		t.Error("Traced error traced again")
+	}
+}
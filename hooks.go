@@ -0,0 +1,81 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// hooks is an immutable set of callbacks. It is replaced as a whole whenever
+// a new hook is registered so that it can be read without locking.
+type hooks struct {
+	raise   []func(Fault)
+	recover []func(error, Call)
+}
+
+// hookSet holds the hooks registered on a Checker.
+type hookSet struct {
+	mu      sync.Mutex
+	current atomic.Value // *hooks
+}
+
+func (h *hookSet) load() *hooks {
+	current, _ := h.current.Load().(*hooks)
+	return current
+}
+
+func (h *hookSet) update(fn func(*hooks)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	next := &hooks{}
+	if current := h.load(); current != nil {
+		*next = *current
+	}
+	fn(next)
+	h.current.Store(next)
+}
+
+func (h *hookSet) raised(fault Fault) {
+	current := h.load()
+	if current == nil {
+		return
+	}
+	for _, fn := range current.raise {
+		fn(fault)
+	}
+}
+
+func (h *hookSet) recovered(err error) {
+	current := h.load()
+	if current == nil || len(current.recover) == 0 {
+		return
+	}
+	site := *StartSite(GetTrace(err))
+	for _, fn := range current.recover {
+		fn(err, site)
+	}
+}
+
+// OnRaise registers fn to be called with every fault raised by True, Truef,
+// Return, Error and Output, just before the fault is panicked. It is safe to
+// call concurrently with other uses of the checker.
+func (c *Checker) OnRaise(fn func(Fault)) *Checker {
+	c.hooks.update(func(h *hooks) {
+		h.raise = append(h.raise[:len(h.raise):len(h.raise)], fn)
+	})
+	return c
+}
+
+// OnRecover registers fn to be called whenever RecoverPanic recovers a fault
+// or converts a panic.
+// err is the error tied to the fault and site is the start site of its trace,
+// which is unknown unless the fault carries a trace. It is safe to call
+// concurrently with other uses of the checker.
+func (c *Checker) OnRecover(fn func(err error, site Call)) *Checker {
+	c.hooks.update(func(h *hooks) {
+		h.recover = append(h.recover[:len(h.recover):len(h.recover)], fn)
+	})
+	return c
+}
@@ -0,0 +1,121 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var raised, recovered []string
+	var sites []Call
+	hooked := NewChecker().SetFaulter(Simple)
+	hooked.OnRaise(func(f Fault) { raised = append(raised, f.Error()) })
+	hooked.OnRecover(func(err error, site Call) {
+		recovered = append(recovered, err.Error())
+		sites = append(sites, site)
+	})
+
+	run := func(fn func()) (err error) {
+		defer hooked.Recover(&err)
+		fn()
+		return
+	}
+
+	for _, fn := range []func(){
+		func() { hooked.True(false, "true") },
+		func() { hooked.Truef(false, "truef %d", 1) },
+		func() { hooked.Return(nil, errors.New("return")) },
+		func() { hooked.Error(errors.New("error")) },
+		func() { hooked.Output("out", errors.New("output")) },
+		func() { hooked.Error(nil) },
+	} {
+		run(fn)
+	}
+
+	expected := []string{"true", "truef 1", "return", "error", "output; output: out"}
+	for name, found := range map[string][]string{"raised": raised, "recovered": recovered} {
+		if len(found) != len(expected) {
+			t.Error("Expected", len(expected), name, "found", found)
+			continue
+		}
+		for i := range expected {
+			if found[i] != expected[i] {
+				t.Error("Expected", expected[i], "found", found[i])
+			}
+		}
+	}
+	if len(sites) == 0 || sites[0].Line != -1 {
+		t.Error("Expected unknown site for simple faults", sites)
+	}
+}
+
+func TestHooksDebugSite(t *testing.T) {
+	if Release {
+		t.Skip("traces are not captured in release builds")
+	}
+	var site Call
+	hooked := NewChecker().OnRecover(func(err error, s Call) { site = s })
+	func() (err error) {
+		defer hooked.Recover(&err)
+		hooked.True(false, "failed")
+		return
+	}()
+	if site.Name != "github.com/surullabs/fault.TestHooksDebugSite.func2" {
+		t.Error("Unexpected site", site.Name)
+	}
+}
+
+func TestHooksConcurrent(t *testing.T) {
+	hooked := NewChecker().SetFaulter(Simple)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			hooked.OnRaise(func(Fault) {})
+		}()
+		go func() {
+			defer wg.Done()
+			func() (err error) {
+				defer hooked.Recover(&err)
+				hooked.True(false, "failed")
+				return
+			}()
+		}()
+	}
+	wg.Wait()
+	if h := hooked.hooks.load(); h == nil || len(h.raise) != 10 {
+		t.Error("Hooks lost during concurrent registration")
+	}
+}
+
+func TestHooksNoAllocation(t *testing.T) {
+	failure := errors.New("failed")
+	// baseline is the raise and recover path of a checker without the calls
+	// to its hooks.
+	baseline := testing.AllocsPerRun(100, func() {
+		func() (err error) {
+			defer func() {
+				if fault, ok := recover().(Fault); ok {
+					err = Chain(fault.Cause(), err)
+				}
+			}()
+			panic(Simple.New(redactError(failure)))
+		}()
+	})
+	unhooked := NewChecker().SetFaulter(Simple)
+	allocs := testing.AllocsPerRun(100, func() {
+		func() (err error) {
+			defer unhooked.Recover(&err)
+			unhooked.Error(failure)
+			return
+		}()
+	})
+	if allocs > baseline {
+		t.Error("Expected at most", baseline, "allocations found", allocs)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+)
+
+// idAlphabet is the Crockford base32 alphabet, which avoids characters that
+// are easily confused when read out by users.
+const idAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newID returns a short random fault id such as F-7K3Q9. Ids only need to
+// tell faults apart in logs, so a fast non-cryptographic source is used.
+func newID() string {
+	n := rand.Uint32()
+	id := []byte("F-00000")
+	for i := len(id) - 1; i >= 2; i-- {
+		id[i] = idAlphabet[n%32]
+		n /= 32
+	}
+	return string(id)
+}
+
+// faultID is the id of a single fault. It is generated the first time it is
+// requested and shared by all errors representing the fault.
+type faultID struct {
+	once sync.Once
+	id   string
+}
+
+func (f *faultID) get() string {
+	if f == nil {
+		return ""
+	}
+	f.once.Do(func() { f.id = newID() })
+	return f.id
+}
+
+// idError carries the id of a fault created by a faulter returned from
+// WithIDs.
+type idError struct {
+	error
+	id *faultID
+}
+
+func (e *idError) Unwrap() error { return e.error }
+
+type idFaulter struct{ Faulter }
+
+func (f idFaulter) New(err error) Fault {
+	return f.Faulter.New(&idError{error: err, id: new(faultID)})
+}
+
+// WithIDs returns a faulter which gives every fault created by f an id. It is
+// meant for faulters such as Simple whose faults recover to the raised error
+// itself and therefore have no id. The id is carried by wrapping the raised
+// error, so recovered errors must be compared using errors.Is.
+//
+//	var check = fault.NewChecker().SetFaulter(fault.WithIDs(fault.Simple))
+func WithIDs(f Faulter) Faulter { return idFaulter{f} }
+
+// ID returns the id of the first fault in err or an empty string if err does
+// not contain a fault with an id. Faults created by a DebugFaulter or by a
+// faulter returned from WithIDs have an id, which is kept when they are
+// chained or traced.
+func ID(err error) string {
+	members := []error{err}
+	if chain, isChain := err.(*ErrorChain); isChain {
+		members = chain.Errors()
+	}
+	for _, member := range members {
+		for member != nil {
+			switch f := member.(type) {
+			case *debugFault:
+				return f.id.get()
+			case *idError:
+				return f.id.get()
+			}
+			member = errors.Unwrap(member)
+		}
+	}
+	return ""
+}
+
+// Reference logs the full trace of err to l under its fault id and returns a
+// message which is safe to show to users and refers to the logged entry.
+//
+//	http.Error(w, fault.Reference(logger, err), http.StatusInternalServerError)
+func Reference(l Logger, err error) string {
+	id := ID(err)
+	if id == "" {
+		l.Print(VerboseTrace(err))
+		return "internal error"
+	}
+	l.Print(id + ": " + VerboseTrace(err))
+	return "internal error, reference " + id
+}
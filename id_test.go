@@ -0,0 +1,98 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var idPattern = regexp.MustCompile(`^F-[0-9A-HJKMNP-TV-Z]{5}$`)
+
+var idCheck = NewChecker().SetFaulter(WithIDs(Simple))
+
+func TestID(t *testing.T) {
+	seen := make(map[string]bool)
+	checkers := []*Checker{idCheck}
+	if !Release {
+		checkers = append(checkers, NewChecker().SetFaulter(DebugFaulter{}))
+	}
+	for _, c := range checkers {
+		for i := 0; i < 10; i++ {
+			err := func() (err error) {
+				defer c.Recover(&err)
+				c.Output("out", errors.New("failed"))
+				return
+			}()
+			id := ID(err)
+			if !idPattern.MatchString(id) {
+				t.Error("Invalid id", id)
+			}
+			if seen[id] {
+				t.Error("Duplicate id", id)
+			}
+			seen[id] = true
+
+			if ID(Chain(errors.New("first"), err)) != id {
+				t.Error("Id lost in chain")
+			}
+			if ID(Traced(err)) != id {
+				t.Error("Id lost when traced")
+			}
+			var report Report
+			json.Unmarshal(NewReport(err).JSON(), &report)
+			if report.Errors[0].ID != id {
+				t.Error("Id lost in report")
+			}
+		}
+	}
+	if ID(errors.New("plain")) != "" {
+		t.Error("Id found for plain error")
+	}
+
+	err := func() (err error) {
+		defer idCheck.Recover(&err)
+		idCheck.Error(io.EOF)
+		return
+	}()
+	if !errors.Is(err, io.EOF) || err.Error() != "EOF" {
+		t.Error("Raised error not wrapped", err)
+	}
+	if ID(func() (err error) {
+		defer check.Recover(&err)
+		check.Error(io.EOF)
+		return
+	}()) != "" {
+		t.Error("Id found for Simple fault")
+	}
+}
+
+func TestReference(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&out, "", 0)
+	err := func() (err error) {
+		defer idCheck.Recover(&err)
+		idCheck.True(false, "secret detail")
+		return
+	}()
+
+	msg := Reference(logger, err)
+	if msg != "internal error, reference "+ID(err) || strings.Contains(msg, "secret") {
+		t.Error("Unexpected public message", msg)
+	}
+	if out.String() != ID(err)+": secret detail\n" {
+		t.Error("Unexpected log", out.String())
+	}
+
+	out.Reset()
+	if msg := Reference(logger, errors.New("plain")); msg != "internal error" || out.String() != "plain\n" {
+		t.Error("Unexpected reference for plain error", msg, out.String())
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// CheckedWriter is an io.Writer which raises a fault on the first write
+// error. The error is sticky: every later call raises it again without
+// writing, so a recovered caller cannot continue a broken encoding. It also
+// provides helpers for writing binary encodings.
+//
+//	func (m *Message) Encode(out io.Writer) (err error) {
+//		defer check.Recover(&err)
+//		w := fault.Writer(check, out)
+//		w.WriteUint32(m.Version)
+//		w.WriteString(m.Name)
+//		return
+//	}
+type CheckedWriter struct {
+	check FaultCheck
+	w     io.Writer
+	err   error // err is the first error, raised again by all later calls
+	n     int64
+	order binary.ByteOrder
+	buf   [binary.MaxVarintLen64]byte
+}
+
+// Writer returns a CheckedWriter writing to w and raising faults using check.
+// Integers are written in big endian byte order.
+func Writer(check FaultCheck, w io.Writer) *CheckedWriter {
+	return &CheckedWriter{check: check, w: w, order: binary.BigEndian}
+}
+
+// SetByteOrder sets the byte order used for fixed size integers.
+func (w *CheckedWriter) SetByteOrder(order binary.ByteOrder) *CheckedWriter {
+	w.order = order
+	return w
+}
+
+// Count returns the number of bytes written.
+func (w *CheckedWriter) Count() int64 { return w.n }
+
+// Err returns the first error raised by the writer, if any.
+func (w *CheckedWriter) Err() error { return w.err }
+
+// Write implements io.Writer. It raises a fault instead of returning an
+// error, including io.ErrShortWrite if the underlying writer writes less than
+// len(p) bytes without an error.
+func (w *CheckedWriter) Write(p []byte) (int, error) {
+	w.check.Error(w.err)
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		w.err = err
+		w.check.Error(err)
+	}
+	return n, nil
+}
+
+// WriteUint8 writes v as a single byte.
+func (w *CheckedWriter) WriteUint8(v uint8) {
+	w.buf[0] = v
+	w.Write(w.buf[:1])
+}
+
+// WriteUint16 writes v in the writer's byte order.
+func (w *CheckedWriter) WriteUint16(v uint16) {
+	w.order.PutUint16(w.buf[:2], v)
+	w.Write(w.buf[:2])
+}
+
+// WriteUint32 writes v in the writer's byte order.
+func (w *CheckedWriter) WriteUint32(v uint32) {
+	w.order.PutUint32(w.buf[:4], v)
+	w.Write(w.buf[:4])
+}
+
+// WriteUint64 writes v in the writer's byte order.
+func (w *CheckedWriter) WriteUint64(v uint64) {
+	w.order.PutUint64(w.buf[:8], v)
+	w.Write(w.buf[:8])
+}
+
+// WriteUvarint writes v as an unsigned varint.
+func (w *CheckedWriter) WriteUvarint(v uint64) {
+	w.Write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+// WriteBytes writes p prefixed with its length as an unsigned varint.
+func (w *CheckedWriter) WriteBytes(p []byte) {
+	w.WriteUvarint(uint64(len(p)))
+	w.Write(p)
+}
+
+// WriteString writes s prefixed with its length as an unsigned varint.
+func (w *CheckedWriter) WriteString(s string) {
+	w.WriteBytes([]byte(s))
+}
+
+// CheckedReader is an io.Reader which raises a fault on the first read error
+// other than io.EOF. Like the error of a CheckedWriter it is sticky, as are
+// faults raised for malformed input. It also provides helpers for reading the
+// encodings written by CheckedWriter.
+type CheckedReader struct {
+	check FaultCheck
+	r     io.Reader
+	err   error // err is the first fault, raised again by all later calls
+	n     int64
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+// Reader returns a CheckedReader reading from r and raising faults using
+// check. Integers are read in big endian byte order.
+func Reader(check FaultCheck, r io.Reader) *CheckedReader {
+	return &CheckedReader{check: check, r: r, order: binary.BigEndian}
+}
+
+// SetByteOrder sets the byte order used for fixed size integers.
+func (r *CheckedReader) SetByteOrder(order binary.ByteOrder) *CheckedReader {
+	r.order = order
+	return r
+}
+
+// Count returns the number of bytes read.
+func (r *CheckedReader) Count() int64 { return r.n }
+
+// Err returns the first fault raised by the reader, if any.
+func (r *CheckedReader) Err() error { return r.err }
+
+// fail records err as the reader's sticky error, if it is the first, and
+// raises it.
+func (r *CheckedReader) fail(err error) {
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		r.check.Error(err)
+	}
+}
+
+// Read implements io.Reader. It returns io.EOF at the end of the input and
+// raises a fault for all other errors.
+func (r *CheckedReader) Read(p []byte) (int, error) {
+	r.check.Error(r.err)
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if err == io.EOF {
+		return n, err
+	}
+	r.fail(err)
+	return n, nil
+}
+
+// ReadByte implements io.ByteReader. It raises a fault at the end of the
+// input.
+func (r *CheckedReader) ReadByte() (byte, error) {
+	r.ReadFull(r.buf[:1])
+	return r.buf[0], nil
+}
+
+// ReadFull fills p, raising a fault carrying io.EOF if the input ends before
+// any bytes are read and io.ErrUnexpectedEOF if it ends part way.
+func (r *CheckedReader) ReadFull(p []byte) {
+	r.check.Error(r.err)
+	n, err := io.ReadFull(r.r, p)
+	r.n += int64(n)
+	r.fail(err)
+}
+
+// More returns true if ReadFull fills p and false if the input ended before
+// any bytes were read. It raises a fault if the input ends part way.
+//
+//	for r.More(header) {
+//		...
+//	}
+func (r *CheckedReader) More(p []byte) bool {
+	r.check.Error(r.err)
+	n, err := io.ReadFull(r.r, p)
+	r.n += int64(n)
+	if err == io.EOF {
+		return false
+	}
+	r.fail(err)
+	return true
+}
+
+// ReadUint8 reads a single byte.
+func (r *CheckedReader) ReadUint8() uint8 {
+	b, _ := r.ReadByte()
+	return b
+}
+
+// ReadUint16 reads a uint16 in the reader's byte order.
+func (r *CheckedReader) ReadUint16() uint16 {
+	r.ReadFull(r.buf[:2])
+	return r.order.Uint16(r.buf[:2])
+}
+
+// ReadUint32 reads a uint32 in the reader's byte order.
+func (r *CheckedReader) ReadUint32() uint32 {
+	r.ReadFull(r.buf[:4])
+	return r.order.Uint32(r.buf[:4])
+}
+
+// ReadUint64 reads a uint64 in the reader's byte order.
+func (r *CheckedReader) ReadUint64() uint64 {
+	r.ReadFull(r.buf[:8])
+	return r.order.Uint64(r.buf[:8])
+}
+
+// ReadUvarint reads an unsigned varint.
+func (r *CheckedReader) ReadUvarint() uint64 {
+	v, err := binary.ReadUvarint(r)
+	r.fail(err)
+	return v
+}
+
+// ReadBytes reads a byte slice written by CheckedWriter.WriteBytes. A length
+// larger than max raises a fault.
+func (r *CheckedReader) ReadBytes(max int) []byte {
+	n := r.ReadUvarint()
+	if n > uint64(max) {
+		const format = "length %d exceeds maximum of %d"
+		r.fail(&templateError{error: fmt.Errorf(format, n, max), format: format})
+	}
+	p := make([]byte, n)
+	r.ReadFull(p)
+	return p
+}
+
+// ReadString reads a string written by CheckedWriter.WriteString. A length
+// larger than max raises a fault.
+func (r *CheckedReader) ReadString(max int) string {
+	return string(r.ReadBytes(max))
+}
@@ -0,0 +1,191 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var ioCheck = NewChecker().SetFaulter(Simple)
+
+type failingWriter struct{ limit int }
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > f.limit {
+		return f.limit, errors.New("disk full")
+	}
+	f.limit -= len(p)
+	return len(p), nil
+}
+
+func encode(out io.Writer, order binary.ByteOrder) (n int64, err error) {
+	defer ioCheck.Recover(&err)
+	w := Writer(ioCheck, out).SetByteOrder(order)
+	w.WriteUint8(1)
+	w.WriteUint16(2)
+	w.WriteUint32(3)
+	w.WriteUint64(4)
+	w.WriteUvarint(300)
+	w.WriteString("hello")
+	w.WriteBytes([]byte{9})
+	return w.Count(), nil
+}
+
+func TestCheckedIO(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		var buf bytes.Buffer
+		n, err := encode(&buf, order)
+		if err != nil || n != int64(buf.Len()) || n != 1+2+4+8+2+6+2 {
+			t.Fatal("Unexpected encoding", n, err)
+		}
+		if order == binary.BigEndian && !bytes.HasPrefix(buf.Bytes(), []byte{1, 0, 2, 0, 0, 0, 3}) {
+			t.Error("Unexpected bytes", buf.Bytes())
+		}
+
+		err = func() (err error) {
+			defer ioCheck.Recover(&err)
+			r := Reader(ioCheck, &buf).SetByteOrder(order)
+			if r.ReadUint8() != 1 || r.ReadUint16() != 2 || r.ReadUint32() != 3 || r.ReadUint64() != 4 ||
+				r.ReadUvarint() != 300 || r.ReadString(10) != "hello" || !bytes.Equal(r.ReadBytes(1), []byte{9}) {
+				t.Error("Decoded values mismatch")
+			}
+			if r.Count() != n {
+				t.Error("Unexpected count", r.Count())
+			}
+			r.ReadUint32()
+			return
+		}()
+		if !errors.Is(err, io.EOF) {
+			t.Error("Expected EOF fault found", err)
+		}
+	}
+}
+
+func TestCheckedWriterFault(t *testing.T) {
+	_, err := encode(&failingWriter{limit: 5}, binary.BigEndian)
+	if err == nil || err.Error() != "disk full" {
+		t.Error("Expected write fault found", err)
+	}
+}
+
+type shortWriter struct{ writes int }
+
+func (s *shortWriter) Write(p []byte) (int, error) {
+	s.writes++
+	return len(p) / 2, nil
+}
+
+func TestCheckedWriterSticky(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		out  io.Writer
+		err  error
+	}{
+		{"failing", &failingWriter{limit: 1}, nil},
+		{"short", &shortWriter{}, io.ErrShortWrite},
+	} {
+		w := Writer(ioCheck, test.out)
+		var errs []error
+		for i := 0; i < 3; i++ {
+			var err error
+			func() {
+				defer ioCheck.Recover(&err)
+				w.WriteUint16(1)
+			}()
+			errs = append(errs, err)
+		}
+		first := w.Err()
+		if first == nil || (test.err != nil && first != test.err) {
+			t.Error(test.name, "unexpected first error", first)
+		}
+		for _, err := range errs {
+			if !errors.Is(err, first) {
+				t.Error(test.name, "error not sticky", err)
+			}
+		}
+	}
+
+	short := &shortWriter{}
+	w := Writer(ioCheck, short)
+	for i := 0; i < 3; i++ {
+		func() (err error) {
+			defer ioCheck.Recover(&err)
+			w.WriteUint32(1)
+			return
+		}()
+	}
+	if short.writes != 1 {
+		t.Error("Writes continued after error", short.writes)
+	}
+}
+
+func TestCheckedReaderSticky(t *testing.T) {
+	r := Reader(ioCheck, strings.NewReader("\x05abcdefgh"))
+	var errs []error
+	for _, read := range []func(){
+		func() { r.ReadString(4) },
+		func() { r.ReadUint8() },
+		func() { r.Read(make([]byte, 1)) },
+		func() { r.More(make([]byte, 1)) },
+	} {
+		var err error
+		func() {
+			defer ioCheck.Recover(&err)
+			read()
+		}()
+		errs = append(errs, err)
+	}
+	for _, err := range errs {
+		if err == nil || err.Error() != "length 5 exceeds maximum of 4" {
+			t.Error("Error not sticky", err)
+		}
+	}
+	if r.Count() != 1 || r.Err() == nil {
+		t.Error("Reads continued after error", r.Count())
+	}
+}
+
+func TestCheckedReaderEOF(t *testing.T) {
+	err := func() (err error) {
+		defer ioCheck.Recover(&err)
+		r := Reader(ioCheck, strings.NewReader("abcdefg"))
+		record := make([]byte, 2)
+		var records []string
+		for r.More(record) {
+			records = append(records, string(record))
+		}
+		t.Error("Partial record not detected", records)
+		return
+	}()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("Expected unexpected EOF found", err)
+	}
+
+	err = func() (err error) {
+		defer ioCheck.Recover(&err)
+		r := Reader(ioCheck, strings.NewReader("abcd"))
+		record := make([]byte, 2)
+		count := 0
+		for r.More(record) {
+			count++
+		}
+		if count != 2 {
+			t.Error("Unexpected record count", count)
+		}
+		data, rerr := io.ReadAll(Reader(ioCheck, strings.NewReader("all")))
+		if string(data) != "all" || rerr != nil {
+			t.Error("EOF not passed through", rerr)
+		}
+		Reader(ioCheck, strings.NewReader("\x05ab")).ReadString(4)
+		return
+	}()
+	if err == nil || err.Error() != "length 5 exceeds maximum of 4" {
+		t.Error("Expected length fault found", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// PanicPolicy controls which non-fault panics RecoverPanic converts into
+// errors instead of propagating them.
+type PanicPolicy int32
+
+const (
+	// PanicPropagate propagates all non-fault panics. It is the default.
+	PanicPropagate PanicPolicy = iota
+	// PanicRuntimeErrors converts panics with a runtime.Error value, such
+	// as nil dereferences and out of range indexes.
+	PanicRuntimeErrors
+	// PanicErrors converts panics with any error value.
+	PanicErrors
+	// PanicAll converts all panics.
+	PanicAll
+)
+
+// SetPanicPolicy sets the policy used by RecoverPanic for non-fault panics.
+// It is safe to call concurrently with other uses of the checker.
+//
+//	var check = fault.NewChecker().SetPanicPolicy(fault.PanicRuntimeErrors)
+func (c *Checker) SetPanicPolicy(p PanicPolicy) *Checker {
+	atomic.StoreInt32(&c.panics, int32(p))
+	return c
+}
+
+// PanicPolicy returns the policy used for non-fault panics.
+func (c *Checker) PanicPolicy() PanicPolicy { return PanicPolicy(atomic.LoadInt32(&c.panics)) }
+
+// PanicError is the error a converted panic is recovered as.
+type PanicError struct {
+	Value interface{} // Value is the value passed to panic
+}
+
+func (p *PanicError) Error() string { return fmt.Sprintf("panic: %v", p.Value) }
+
+// Unwrap returns the panic value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// IsPanic returns true if err contains an error converted from a panic.
+func IsPanic(err error) bool {
+	var p *PanicError
+	return errors.As(err, &p)
+}
+
+// convertPanic returns the error for panicked or nil if the panic policy does
+// not convert it. It must be called by RecoverPanic while the panic is still
+// in progress so that the trace starts at the panic site.
+func (c *Checker) convertPanic(panicked interface{}) error {
+	switch c.PanicPolicy() {
+	case PanicPropagate:
+		return nil
+	case PanicRuntimeErrors:
+		if _, isRuntime := panicked.(runtime.Error); !isRuntime {
+			return nil
+		}
+	case PanicErrors:
+		if _, isError := panicked.(error); !isError {
+			return nil
+		}
+	}
+
+	err := &PanicError{Value: panicked}
+	trace := panicTrace(Callers(1))
+	if len(trace) == 0 {
+		return err
+	}
+	return &debugFault{err: err, trace: trace, id: new(faultID)}
+}
+
+// panicTrace returns the part of trace below the call to panic, dropping the
+// runtime frames which raise runtime errors.
+func panicTrace(trace []Call) []Call {
+	start := 0
+	for i := range trace {
+		if trace[i].Name == "runtime.gopanic" {
+			start = i + 1
+		}
+	}
+	for start < len(trace) && trace[start].Package() == "runtime" {
+		start++
+	}
+	return trace[start:]
+}
+
+// SetPanicStacks controls whether RecoverPanic re-panics non-fault values as
+// they are or wrapped in a *Repanic carrying the stack of the original panic.
+// It is disabled by default and is safe to call concurrently with other uses
+// of the checker.
+func (c *Checker) SetPanicStacks(enabled bool) *Checker {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&c.stacks, value)
+	return c
+}
+
+// Repanic is the value RecoverPanic re-panics with if panic stacks are
+// enabled. Its error message includes the stack captured at the recovery point,
+// which still contains the frames leading to the original panic, while the
+// crash output of the runtime shows the recovery point itself.
+type Repanic struct {
+	Value interface{} // Value is the original panic value
+	Stack []byte      // Stack is the goroutine stack at the recovery point
+}
+
+func (r *Repanic) Error() string {
+	return fmt.Sprintf("%v [recovered]\n\noriginal panic stack:\n%s", r.Value, r.Stack)
+}
+
+// Unwrap returns the original panic value if it is an error.
+func (r *Repanic) Unwrap() error {
+	err, _ := r.Value.(error)
+	return err
+}
+
+// repanicValue returns the value RecoverPanic re-panics with.
+func (c *Checker) repanicValue(panicked interface{}) interface{} {
+	if atomic.LoadInt32(&c.stacks) == 0 {
+		return panicked
+	}
+	if _, wrapped := panicked.(*Repanic); wrapped {
+		return panicked
+	}
+	buf := make([]byte, 16<<10)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return &Repanic{Value: panicked, Stack: buf[:n]}
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
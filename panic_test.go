@@ -0,0 +1,124 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type panicStruct struct{ value int }
+
+func nilDereference() int {
+	var p *panicStruct
+	return p.value
+}
+
+func runPolicy(check *Checker, fn func()) (err error, repanicked interface{}) {
+	defer func() { repanicked = recover() }()
+	err = func() (err error) {
+		defer check.Recover(&err)
+		fn()
+		return
+	}()
+	return
+}
+
+func TestPanicPolicy(t *testing.T) {
+	errPanic := errors.New("error panic")
+	for _, test := range []struct {
+		policy    PanicPolicy
+		fn        func()
+		converted bool
+	}{
+		{PanicPropagate, func() { nilDereference() }, false},
+		{PanicRuntimeErrors, func() { nilDereference() }, true},
+		{PanicRuntimeErrors, func() { panic(errPanic) }, false},
+		{PanicErrors, func() { panic(errPanic) }, true},
+		{PanicErrors, func() { panic("string") }, false},
+		{PanicAll, func() { panic("string") }, true},
+	} {
+		check := NewChecker().SetPanicPolicy(test.policy)
+		if check.PanicPolicy() != test.policy {
+			t.Error("Policy not set")
+		}
+		err, repanicked := runPolicy(check, test.fn)
+		if test.converted != (repanicked == nil) {
+			t.Error("Policy", test.policy, "unexpected conversion", err, repanicked)
+		} else if test.converted && !IsPanic(err) {
+			t.Error("Converted panic not marked", err)
+		}
+	}
+
+	if IsPanic(errPanic) {
+		t.Error("Plain error marked as panic")
+	}
+	err, _ := runPolicy(NewChecker().SetPanicPolicy(PanicErrors), func() { panic(errPanic) })
+	if !errors.Is(err, errPanic) || !strings.HasSuffix(err.Error(), "panic: error panic") {
+		t.Error("Panic value not wrapped", err)
+	}
+}
+
+func TestPanicTrace(t *testing.T) {
+	if Release {
+		t.Skip("traces are not captured in release builds")
+	}
+	err, _ := runPolicy(NewChecker().SetPanicPolicy(PanicAll), func() { nilDereference() })
+	var rtErr runtime.Error
+	if !errors.As(err, &rtErr) {
+		t.Error("Runtime error not wrapped", err)
+	}
+	if site := StartSite(GetTrace(err)).Name; site != "github.com/surullabs/fault.nilDereference" {
+		t.Error("Unexpected panic site", site)
+	}
+}
+
+func TestPanicRollback(t *testing.T) {
+	check := NewChecker().SetPanicPolicy(PanicAll)
+	undone := false
+	err := func() (err error) {
+		undo := check.Rollback()
+		defer undo.Recover(&err)
+		undo.OnFault(func() { undone = true })
+		panic("failed")
+	}()
+	if !undone || !IsPanic(err) {
+		t.Error("Converted panic not rolled back", err)
+	}
+}
+
+func TestPanicStacks(t *testing.T) {
+	err, repanicked := runPolicy(NewChecker(), func() { nilDereference() })
+	if _, isRuntime := repanicked.(runtime.Error); !isRuntime || err != nil {
+		t.Error("Panic value changed by default", repanicked)
+	}
+
+	check := NewChecker().SetPanicStacks(true)
+	_, repanicked = runPolicy(check, func() { nilDereference() })
+	wrapped, ok := repanicked.(*Repanic)
+	if !ok {
+		t.Fatal("Panic not wrapped", repanicked)
+	}
+	var rtErr runtime.Error
+	if !errors.As(wrapped, &rtErr) {
+		t.Error("Original value not unwrapped")
+	}
+	msg := wrapped.Error()
+	if !strings.Contains(msg, "nil pointer dereference [recovered]") ||
+		!strings.Contains(msg, "fault.nilDereference") {
+		t.Error("Original stack missing from", msg)
+	}
+
+	_, repanicked = runPolicy(check, func() { panic(wrapped) })
+	if repanicked != wrapped {
+		t.Error("Panic wrapped twice")
+	}
+
+	check.SetPanicStacks(false)
+	if _, repanicked = runPolicy(check, func() { panic("string") }); repanicked != "string" {
+		t.Error("Panic stacks not disabled")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// publicError attaches a message which is safe to show to users to an error
+// whose own message is for internal use only.
+type publicError struct {
+	error
+	public string
+}
+
+func (p *publicError) Unwrap() error { return p.error }
+
+// WithPublic returns err with msg attached as its public message. The message
+// returned by err.Error() is unchanged. err is returned unchanged if it is nil
+// or msg is empty.
+func WithPublic(err error, msg string) error {
+	if err == nil || msg == "" {
+		return err
+	}
+	return &publicError{error: err, public: msg}
+}
+
+// Public returns a checker whose faults carry msg as their public message
+// while their error message keeps all internal details.
+//
+//	check.Public("could not save document").Error(err)
+func (c *Checker) Public(msg string) *ContextChecker {
+	return c.In(context.Background()).Public(msg)
+}
+
+// PublicMessage returns text describing err which is safe to show to users.
+// It joins the distinct public messages of the errors in the chain. If there
+// are none it returns a generic message which refers to the fault id of err
+// if it has one.
+func PublicMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	members := []error{err}
+	if chain, isChain := err.(*ErrorChain); isChain {
+		members = chain.Errors()
+	}
+	var messages []string
+	seen := make(map[string]bool)
+	for _, member := range members {
+		var p *publicError
+		if errors.As(member, &p) && !seen[p.public] {
+			seen[p.public] = true
+			messages = append(messages, p.public)
+		}
+	}
+	if len(messages) > 0 {
+		return strings.Join(messages, "; ")
+	}
+	if id := ID(err); id != "" {
+		return "internal error, reference " + id
+	}
+	return "internal error"
+}
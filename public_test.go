@@ -0,0 +1,64 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPublicMessage(t *testing.T) {
+	check := NewChecker().SetFaulter(Simple)
+	saveErr := errors.New("open /home/user/doc.txt: permission denied")
+	err := func() (err error) {
+		defer check.Recover(&err)
+		check.Public("could not save document").Error(saveErr)
+		return
+	}()
+	if err.Error() != saveErr.Error() || !errors.Is(err, saveErr) {
+		t.Error("Internal message changed", err)
+	}
+	if Fingerprint(err) != Fingerprint(check.Failure(saveErr)) {
+		t.Error("Fingerprint includes public message")
+	}
+	if PublicMessage(err) != "could not save document" {
+		t.Error("Unexpected public message", PublicMessage(err))
+	}
+
+	chained := Chain(err, WithPublic(errors.New("detail"), "could not save document"),
+		WithPublic(errors.New("detail"), "quota exceeded"), errors.New("internal"))
+	if msg := PublicMessage(chained); msg != "could not save document; quota exceeded" {
+		t.Error("Unexpected combined message", msg)
+	}
+
+	internal := func() (err error) {
+		defer idCheck.Recover(&err)
+		idCheck.Output("token=abc", errors.New("failed"))
+		return
+	}()
+	if msg := PublicMessage(internal); msg != "internal error, reference "+ID(internal) {
+		t.Error("Unexpected message for internal fault", msg)
+	}
+	if PublicMessage(errors.New("plain")) != "internal error" || PublicMessage(nil) != "" {
+		t.Error("Unexpected message for plain error")
+	}
+	if WithPublic(saveErr, "") != saveErr || WithPublic(nil, "msg") != nil {
+		t.Error("Public message added unnecessarily")
+	}
+}
+
+func TestPublicIn(t *testing.T) {
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	check := NewChecker().In(ctx).Public("request failed")
+	err := func() (err error) {
+		defer check.Recover(&err)
+		check.Truef(false, "row %d missing", 3)
+		return
+	}()
+	if PublicMessage(err) != "request failed" || !strings.HasSuffix(err.Error(), "row 3 missing [request_id=req-1]") {
+		t.Error("Unexpected error", err, PublicMessage(err))
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+// Logger is the interface used by RecoverLog. It is implemented by
+// *log.Logger.
+type Logger interface {
+	Print(v ...interface{})
+}
+
+// RecoverTo works like Recover for functions without an error result. The
+// recovered error is passed to fn, which is not called if there was no fault.
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		defer check.RecoverTo(func(err error) {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//		})
+//		...
+//	}
+func (c *Checker) RecoverTo(fn func(error)) {
+	c.recoverTo(fn, recover())
+}
+
+// RecoverToChan works like RecoverTo, sending the recovered error on ch.
+func (c *Checker) RecoverToChan(ch chan<- error) {
+	c.recoverTo(func(err error) { ch <- err }, recover())
+}
+
+// RecoverLog works like RecoverTo, printing the recovered error to l.
+func (c *Checker) RecoverLog(l Logger) {
+	c.recoverTo(func(err error) { l.Print(err) }, recover())
+}
+
+func (c *Checker) recoverTo(fn func(error), panicked interface{}) {
+	var err error
+	c.RecoverPanic(&err, panicked)
+	if err != nil {
+		fn(err)
+	}
+}
+
+// RecoverAll works like Recover and also calls reset if a fault was
+// recovered, so that partially assigned named results can be cleared before
+// they are returned along with the error. The closure keeps the results on the
+// stack, so the path without a fault does not allocate.
+//
+//	func Parse(data []byte) (doc *Document, n int, err error) {
+//		defer check.RecoverAll(&err, func() { doc, n = nil, 0 })
+//		...
+//	}
+func (c *Checker) RecoverAll(errPtr *error, reset func()) {
+	panicked := recover()
+	c.RecoverPanic(errPtr, panicked)
+	if panicked != nil {
+		reset()
+	}
+}
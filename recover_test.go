@@ -0,0 +1,106 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"testing"
+)
+
+var recoverCheck = NewChecker().SetFaulter(Simple)
+
+func TestRecoverTo(t *testing.T) {
+	var recovered []error
+	for _, fail := range []bool{true, false} {
+		func() {
+			defer recoverCheck.RecoverTo(func(err error) { recovered = append(recovered, err) })
+			recoverCheck.True(!fail, "error1")
+		}()
+	}
+	if len(recovered) != 1 || recovered[0].Error() != "error1" {
+		t.Error("Unexpected errors", recovered)
+	}
+}
+
+func TestRecoverToChan(t *testing.T) {
+	ch := make(chan error, 1)
+	go func() {
+		defer recoverCheck.RecoverToChan(ch)
+		recoverCheck.Error(errors.New("error1"))
+	}()
+	if err := <-ch; err == nil || err.Error() != "error1" {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestRecoverLog(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&out, "", 0)
+	func() {
+		defer recoverCheck.RecoverLog(logger)
+		recoverCheck.Truef(false, "error%d", 1)
+	}()
+	func() {
+		defer recoverCheck.RecoverLog(logger)
+	}()
+	if out.String() != "error1\n" {
+		t.Error("Unexpected log output", out.String())
+	}
+}
+
+func TestRecoverToPanic(t *testing.T) {
+	defer func() {
+		if e := recover(); e == nil || e.(string) != "different panic" {
+			t.Error("Not recovered")
+		}
+	}()
+	func() {
+		defer recoverCheck.RecoverTo(func(err error) { t.Error("Non fault panic recovered", err) })
+		panic("different panic")
+	}()
+}
+
+type partial struct {
+	name  string
+	items []string
+}
+
+func buildPartial(fail bool) (result *partial, count int, name string, err error) {
+	defer recoverCheck.RecoverAll(&err, func() { result, count, name = nil, 0, "" })
+	result = &partial{name: "half"}
+	count, name = 3, "built"
+	recoverCheck.True(!fail, "error1")
+	result.items = []string{"a"}
+	return
+}
+
+func TestRecoverAll(t *testing.T) {
+	result, count, name, err := buildPartial(true)
+	if err == nil || err.Error() != "error1" {
+		t.Error("Unexpected error", err)
+	}
+	if result != nil || count != 0 || name != "" {
+		t.Error("Results not reset", result, count, name)
+	}
+
+	result, count, name, err = buildPartial(false)
+	if err != nil || result == nil || len(result.items) != 1 || count != 3 || name != "built" {
+		t.Error("Results reset without fault", result, count, name, err)
+	}
+}
+
+func TestRecoverAllNoAllocation(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		func() (count int, name string, err error) {
+			defer recoverCheck.RecoverAll(&err, func() { count, name = 0, "" })
+			count, name = 3, "built"
+			return
+		}()
+	})
+	if allocs != 0 {
+		t.Error("Unexpected allocations", allocs)
+	}
+}
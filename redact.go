@@ -0,0 +1,125 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// Redacted is the text secrets are replaced with.
+const Redacted = "***"
+
+// Redactor removes secrets from text.
+type Redactor func(string) string
+
+// RedactRule replaces all matches of Pattern with Replacement, which may refer
+// to submatches as in regexp.Regexp.ReplaceAllString.
+type RedactRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultRedactRules are the built-in rules for common credentials.
+var DefaultRedactRules = []RedactRule{
+	// PEM encoded private keys
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), Redacted},
+	// Authorization headers
+	{regexp.MustCompile(`(?i)\b((?:proxy-)?authorization["']?\s*[:=]\s*["']?(?:(?:bearer|basic|digest|token)\s+)?)[^\s"',;]+`), "${1}" + Redacted},
+	// Bearer tokens outside of headers, which must look like credentials
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-._~+/]{16,}=*`), "${1}" + Redacted},
+	// Passwords in URLs
+	{regexp.MustCompile(`(\b[a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+:)[^@/\s]+@`), "${1}" + Redacted + "@"},
+	// key=value and key: value pairs with sensitive keys
+	{regexp.MustCompile(`(?i)\b([a-z0-9_\-]*(?:password|passwd|pwd|secret|token|api[_\-]?key|access[_\-]?key)[a-z0-9_\-]*["']?\s*[:=]\s*["']?)[^\s"'&,;]+`), "${1}" + Redacted},
+	// AWS access key ids
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), Redacted},
+	// GitHub tokens
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`), Redacted},
+	// Slack tokens
+	{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}\b`), Redacted},
+}
+
+var redaction struct {
+	sync.Mutex
+	rules    []RedactRule
+	redactor atomic.Value // redactorValue
+}
+
+type redactorValue struct{ Redactor }
+
+func init() {
+	redaction.rules = DefaultRedactRules
+	SetRedactor(RedactRules)
+}
+
+// AddRedactRule adds a rule applied by RedactRules. It is safe to call
+// concurrently with the creation and rendering of faults.
+func AddRedactRule(pattern *regexp.Regexp, replacement string) {
+	redaction.Lock()
+	defer redaction.Unlock()
+	rules := make([]RedactRule, len(redaction.rules), len(redaction.rules)+1)
+	copy(rules, redaction.rules)
+	redaction.rules = append(rules, RedactRule{pattern, replacement})
+}
+
+// RedactRules applies DefaultRedactRules and all rules added using
+// AddRedactRule to s. It is the default Redactor.
+func RedactRules(s string) string {
+	redaction.Lock()
+	rules := redaction.rules
+	redaction.Unlock()
+	for _, rule := range rules {
+		s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+	}
+	return s
+}
+
+// SetRedactor sets the Redactor applied to the messages of faults when they
+// are created and to traces and reports when they are rendered. A nil
+// redactor disables redaction.
+func SetRedactor(r Redactor) { redaction.redactor.Store(redactorValue{r}) }
+
+// Redact applies the current Redactor to s.
+func Redact(s string) string {
+	if r := redaction.redactor.Load().(redactorValue).Redactor; r != nil {
+		return r(s)
+	}
+	return s
+}
+
+// redactedError replaces the message of an error with its redacted form.
+type redactedError struct {
+	error
+	msg string
+}
+
+func (r *redactedError) Error() string { return r.msg }
+func (r *redactedError) Unwrap() error { return r.error }
+
+// redactError returns err with its message redacted, or err itself if the
+// message contains no secrets.
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if redacted := Redact(msg); redacted != msg {
+		return &redactedError{error: err, msg: redacted}
+	}
+	return err
+}
+
+// secret is a value which is never printed.
+type secret struct{ value interface{} }
+
+func (secret) Format(f fmt.State, verb rune) { f.Write([]byte(Redacted)) }
+
+// Secret wraps v so that it is printed as *** by all fmt verbs. It is meant for
+// arguments to Truef and similar functions.
+//
+//	check.Truef(valid, "invalid credentials for %s: %s", user, fault.Secret(password))
+func Secret(v interface{}) interface{} { return secret{v} }
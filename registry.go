@@ -0,0 +1,143 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TraceEnv is the environment variable consulted when a checker is registered.
+// It holds a comma separated list of patterns as described by SetTracing. If
+// it is set, registered checkers whose names match one of the patterns have
+// tracing enabled and all others have it disabled.
+//
+//	FAULT_TRACE=mypkg/*,otherpkg
+const TraceEnv = "FAULT_TRACE"
+
+var registry = struct {
+	sync.Mutex
+	checkers map[string]*Checker
+}{checkers: make(map[string]*Checker)}
+
+// Register adds c to the registry of named checkers so that its tracing can be
+// switched at runtime. Packages typically register a checker under their
+// import path.
+//
+//	var check = fault.Register("github.com/me/mypkg", fault.NewChecker())
+//
+// Registering a second checker under the same name replaces the first.
+func Register(name string, c *Checker) *Checker {
+	if patterns, set := os.LookupEnv(TraceEnv); set {
+		c.SetTracing(matchAny(strings.Split(patterns, ","), name))
+	}
+	registry.Lock()
+	registry.checkers[name] = c
+	registry.Unlock()
+	return c
+}
+
+// Lookup returns the checker registered under name or nil if there is none.
+func Lookup(name string) *Checker {
+	registry.Lock()
+	defer registry.Unlock()
+	return registry.checkers[name]
+}
+
+// Registered returns the names of all registered checkers in sorted order.
+func Registered() []string {
+	registry.Lock()
+	names := make([]string, 0, len(registry.checkers))
+	for name := range registry.checkers {
+		names = append(names, name)
+	}
+	registry.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// SetTracing enables or disables tracing on all registered checkers whose
+// names match pattern and returns the number of checkers switched. Patterns
+// use the syntax of path.Match, except that a trailing /... or /* matches the
+// preceding path and everything below it at any depth. A pattern also matches
+// names which end with a match, so mypkg/* matches github.com/me/mypkg and
+// github.com/me/mypkg/sub/x.
+func SetTracing(pattern string, enabled bool) (switched int) {
+	registry.Lock()
+	defer registry.Unlock()
+	for name, c := range registry.checkers {
+		if matchAny([]string{pattern}, name) {
+			c.SetTracing(enabled)
+			switched++
+		}
+	}
+	return
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		for suffix := name; ; {
+			if matchPath(pattern, suffix) {
+				return true
+			}
+			slash := strings.Index(suffix, "/")
+			if slash < 0 {
+				break
+			}
+			suffix = suffix[slash+1:]
+		}
+	}
+	return false
+}
+
+// matchPath matches name against pattern, treating a trailing /... or /* as a
+// match for the preceding path and everything below it.
+func matchPath(pattern, name string) bool {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(pattern, "/..."), "/*")
+	if prefix == pattern {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	for end := len(name); end > 0; end = strings.LastIndex(name[:end], "/") {
+		if matched, _ := path.Match(prefix, name[:end]); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTracing switches the checker between a DebugFaulter and WithIDs(Simple),
+// so faults keep an id while tracing is off. The swap
+// is atomic and may be done while the checker is in use. Enabling tracing
+// restores the last DebugFaulter set on the checker, keeping its Prefix and
+// Filter, or a default DebugFaulter if there was none. Release builds never
+// capture traces, so enabling tracing is a no-op there.
+func (c *Checker) SetTracing(enabled bool) *Checker {
+	if !enabled {
+		return c.SetFaulter(WithIDs(Simple))
+	} else if Release || c.Tracing() {
+		return c
+	} else if debug, ok := c.debug.Load().(faulterValue); ok {
+		return c.SetFaulter(debug.Faulter)
+	}
+	return c.SetFaulter(&DebugFaulter{})
+}
+
+// Tracing returns true if the checker's faulter is a DebugFaulter. It always
+// returns false in release builds.
+func (c *Checker) Tracing() bool {
+	return !Release && isTracing(c.Faulter())
+}
+
+func isTracing(f Faulter) bool {
+	switch f.(type) {
+	case DebugFaulter, *DebugFaulter:
+		return true
+	}
+	return false
+}
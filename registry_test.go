@@ -0,0 +1,141 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	if Release {
+		t.Skip("tracing cannot be enabled in release builds")
+	}
+	a := Register("test/registry/a", NewChecker().SetTracing(true))
+	b := Register("test/registry/b", NewChecker().SetFaulter(Simple))
+	other := Register("test/other", NewChecker().SetTracing(true))
+
+	if Lookup("test/registry/a") != a || Lookup("missing") != nil {
+		t.Error("Lookup failed")
+	}
+	names := Registered()
+	found := 0
+	for _, name := range names {
+		if name == "test/registry/a" || name == "test/registry/b" || name == "test/other" {
+			found++
+		}
+	}
+	if found != 3 {
+		t.Error("Registered names missing", names)
+	}
+
+	if !a.Tracing() || b.Tracing() {
+		t.Error("Unexpected initial tracing state")
+	}
+	if n := SetTracing("test/registry/*", false); n != 2 {
+		t.Error("Expected 2 switched found", n)
+	}
+	if a.Tracing() || b.Tracing() || !other.Tracing() {
+		t.Error("Tracing not disabled")
+	}
+	if n := SetTracing("test/registry/b", true); n != 1 {
+		t.Error("Expected 1 switched found", n)
+	}
+	if a.Tracing() || !b.Tracing() {
+		t.Error("Tracing not enabled")
+	}
+}
+
+func TestSetTracingKeepsFaulter(t *testing.T) {
+	if Release {
+		t.Skip("tracing cannot be enabled in release builds")
+	}
+	filter := &TraceFilter{}
+	c := Register("test/keep/a", NewChecker().SetFaulter(DebugFaulter{Prefix: "pkg", Filter: filter}))
+	for _, enabled := range []bool{false, true, true} {
+		SetTracing("test/keep/*", enabled)
+	}
+	if f, ok := c.Faulter().(DebugFaulter); !ok || f.Filter != filter || f.Prefix != "pkg" {
+		t.Error("Configured faulter not restored", c.Faulter())
+	}
+
+	defer os.Unsetenv(TraceEnv)
+	os.Setenv(TraceEnv, "test/keep/*")
+	c = Register("test/keep/b", NewChecker().SetFaulter(DebugFaulter{Filter: filter}))
+	if f, ok := c.Faulter().(DebugFaulter); !ok || f.Filter != filter {
+		t.Error("Configured faulter replaced on register", c.Faulter())
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	for _, test := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"mypkg", "mypkg", true},
+		{"mypkg", "github.com/me/mypkg", true},
+		{"mypkg", "github.com/me/mypkg/sub", false},
+		{"mypkg/*", "mypkg", true},
+		{"mypkg/*", "mypkg/a", true},
+		{"mypkg/*", "mypkg/sub/x", true},
+		{"mypkg/*", "github.com/me/mypkg/sub/x", true},
+		{"mypkg/*", "mypkgs/a", false},
+		{"mypkg/...", "github.com/me/mypkg", true},
+		{"github.com/me/...", "github.com/me/mypkg/sub", true},
+		{"github.com/me/...", "github.com/you/mypkg", false},
+		{"my*/sub", "github.com/me/mypkg/sub", true},
+		{"other", "github.com/me/mypkg", false},
+	} {
+		if matchAny([]string{test.pattern}, test.name) != test.match {
+			t.Error("Unexpected match of", test.pattern, "against", test.name)
+		}
+	}
+}
+
+func TestRegisterEnv(t *testing.T) {
+	if Release {
+		t.Skip("tracing cannot be enabled in release builds")
+	}
+	defer os.Unsetenv(TraceEnv)
+	os.Setenv(TraceEnv, "test/env/*, test/exact")
+
+	for _, test := range []struct {
+		name    string
+		tracing bool
+	}{
+		{"test/env/a", true},
+		{"test/exact", true},
+		{"test/env", true},
+		{"test/env/a/b", true},
+		{"test/environment", false},
+		{"test/other", false},
+	} {
+		if Register(test.name, NewChecker()).Tracing() != test.tracing {
+			t.Error("Unexpected tracing state for", test.name)
+		}
+	}
+}
+
+func TestSetTracingConcurrent(t *testing.T) {
+	c := NewChecker()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(enabled bool) {
+			defer wg.Done()
+			c.SetTracing(enabled)
+		}(i%2 == 0)
+		go func() {
+			defer wg.Done()
+			func() (err error) {
+				defer c.Recover(&err)
+				c.Error(errors.New("failed"))
+				return
+			}()
+		}()
+	}
+	wg.Wait()
+}
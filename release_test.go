@@ -0,0 +1,54 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+//go:build faultrelease
+// +build faultrelease
+
+package fault
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRelease(t *testing.T) {
+	if NewChecker().Tracing() {
+		t.Error("Release checker is tracing")
+	}
+	if c := NewChecker().SetTracing(true); c.Tracing() || c.Faulter() != WithIDs(Simple) {
+		t.Error("Tracing enabled in release build")
+	}
+	if NewChecker().SetFaulter(DebugFaulter{}).Tracing() {
+		t.Error("Debug faulter reported as tracing in release build")
+	}
+	if trace := ReadStack(""); len(trace) != 0 {
+		t.Error("Stack read in release build", trace)
+	}
+
+	err := errors.New("err")
+	if Traced(err) != err {
+		t.Error("Traced wrapped error")
+	}
+	if VerboseTrace(err) != "err" {
+		t.Error("Unexpected verbose trace", VerboseTrace(err))
+	}
+
+	c := NewChecker()
+	recovered := func() (err error) {
+		defer c.Recover(&err)
+		c.Error(io.EOF)
+		return
+	}()
+	if id := ID(recovered); !idPattern.MatchString(id) || !errors.Is(recovered, io.EOF) {
+		t.Error("Release fault has no id", id, recovered)
+	}
+	if msg := PublicMessage(recovered); msg != "internal error, reference "+ID(recovered) {
+		t.Error("Unexpected public message", msg)
+	}
+
+	fault := DebugFaulter{}.New(err)
+	if fault.Error() != "err" || GetTrace(fault) != nil {
+		t.Error("Debug fault created in release build", fault.Error())
+	}
+}
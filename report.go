@@ -0,0 +1,263 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report holds the information written by a Reporter.
+type Report struct {
+	Time       time.Time         `json:"time"`
+	Error      string            `json:"error"`
+	Errors     []ReportError     `json:"errors"`
+	GoVersion  string            `json:"go_version"`
+	Build      *debug.BuildInfo  `json:"build,omitempty"`
+	MemStats   *runtime.MemStats `json:"mem_stats,omitempty"`
+	Goroutines string            `json:"goroutines"`
+	Truncated  bool              `json:"truncated,omitempty"` // Truncated is set if parts were dropped to fit a size cap
+}
+
+// ReportError describes a single error in the chain of a report.
+type ReportError struct {
+	ID          string  `json:"id,omitempty"`
+	Error       string  `json:"error"`
+	Fingerprint string  `json:"fingerprint"`
+	Fields      []Field `json:"fields,omitempty"`
+	Trace       []Call  `json:"trace,omitempty"`
+}
+
+// NewReport collects a report for err.
+func NewReport(err error) *Report {
+	r := &Report{Time: time.Now(), GoVersion: runtime.Version()}
+	if err != nil {
+		r.Error = Redact(err.Error())
+		members := []error{err}
+		if chain, isChain := err.(*ErrorChain); isChain {
+			members = chain.Errors()
+		}
+		for _, member := range members {
+			r.Errors = append(r.Errors, ReportError{
+				ID:          ID(member),
+				Error:       Redact(member.Error()),
+				Fingerprint: Fingerprint(member),
+				Fields:      redactFields(Fields(member)),
+				Trace:       GetTrace(member),
+			})
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		r.Build = info
+	}
+	r.MemStats = new(runtime.MemStats)
+	runtime.ReadMemStats(r.MemStats)
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			r.Goroutines = string(buf[:n])
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return r
+}
+
+// redactFields returns fields with all string values redacted.
+func redactFields(fields []Field) []Field {
+	for i := range fields {
+		if s, isString := fields[i].Value.(string); isString {
+			fields[i].Value = Redact(s)
+		}
+	}
+	return fields
+}
+
+// Text renders the report in a human readable form.
+func (r *Report) Text() []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "time: %s\n", r.Time.Format(time.RFC3339Nano))
+	fmt.Fprintf(&out, "error: %s\n", r.Error)
+	for i, e := range r.Errors {
+		fmt.Fprintf(&out, "\nerror %d: %s\nid: %s\nfingerprint: %s\n", i, e.Error, e.ID, e.Fingerprint)
+		for _, field := range e.Fields {
+			fmt.Fprintf(&out, "field: %s\n", field)
+		}
+		for _, call := range e.Trace {
+			if _, elided := call.Elided(); elided {
+				fmt.Fprintf(&out, "\t%s\n", call.String())
+			} else {
+				fmt.Fprintf(&out, "\t%s\n\t\t%s:%d\n", call.Name, call.File, call.Line)
+			}
+		}
+	}
+	fmt.Fprintf(&out, "\ngo version: %s\n", r.GoVersion)
+	if r.Build != nil {
+		fmt.Fprintf(&out, "\nbuild info:\n%s\n", r.Build)
+	}
+	if m := r.MemStats; m != nil {
+		fmt.Fprintf(&out, "\nmemory: alloc=%d total_alloc=%d sys=%d heap_objects=%d num_gc=%d\n",
+			m.Alloc, m.TotalAlloc, m.Sys, m.HeapObjects, m.NumGC)
+	}
+	fmt.Fprintf(&out, "\ngoroutines:\n%s\n", r.Goroutines)
+	if r.Truncated {
+		out.WriteString(truncatedMarker)
+	}
+	return out.Bytes()
+}
+
+// JSON renders the report as indented JSON.
+func (r *Report) JSON() []byte {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return data
+}
+
+const (
+	// DefaultMaxReports is the number of reports kept by WriteReport.
+	DefaultMaxReports = 10
+	// DefaultMaxReportSize is the size cap in bytes applied by WriteReport.
+	DefaultMaxReportSize = 1 << 20
+)
+
+// Reporter writes reports to a directory. Each report is written as a text
+// file and a JSON file sharing the same name.
+type Reporter struct {
+	// Dir is the directory reports are written to. It is created if needed.
+	Dir string
+	// MaxReports is the number of reports kept in Dir. Older reports are
+	// removed when a new one is written. Zero keeps all reports.
+	MaxReports int
+	// MaxSize caps the size in bytes of each report file. Parts of the report
+	// are dropped until it fits: the goroutine dump first, then the memory
+	// statistics and build information, then the traces and fields, then the
+	// individual errors and finally the error message. Reports are never cut
+	// at an arbitrary byte, so a cap smaller than an otherwise empty report is
+	// exceeded. Zero disables the cap.
+	MaxSize int
+}
+
+var reportCheck = NewChecker().SetFaulter(Simple)
+
+// WriteReport writes a report for err to dir using the default limits and
+// returns the path of the text report.
+func WriteReport(dir string, err error) (string, error) {
+	r := &Reporter{Dir: dir, MaxReports: DefaultMaxReports, MaxSize: DefaultMaxReportSize}
+	return r.Write(err)
+}
+
+// ReportOnPanic writes a report to dir if the calling goroutine is panicking
+// and then continues the panic. It must be deferred directly.
+//
+//	func main() {
+//		defer fault.ReportOnPanic("/var/log/myapp")
+//		...
+//	}
+func ReportOnPanic(dir string) {
+	if panicked := recover(); panicked != nil {
+		WriteReport(dir, panicError(panicked))
+		panic(panicked)
+	}
+}
+
+func panicError(panicked interface{}) error {
+	switch p := panicked.(type) {
+	case Fault:
+		return p.Cause()
+	case error:
+		return p
+	default:
+		return fmt.Errorf("panic: %v", p)
+	}
+}
+
+// Write writes a report for err and returns the path of the text report.
+func (r *Reporter) Write(err error) (path string, werr error) {
+	defer reportCheck.Recover(&werr)
+
+	report := NewReport(err)
+	reportCheck.Error(os.MkdirAll(r.Dir, 0755))
+	stem := filepath.Join(r.Dir, "fault-"+report.Time.UTC().Format("20060102T150405.000000000"))
+	path = stem + ".txt"
+	reportCheck.Error(ioutil.WriteFile(path, r.limit(report, (*Report).Text), 0644))
+	reportCheck.Error(ioutil.WriteFile(stem+".json", r.limit(report, (*Report).JSON), 0644))
+	r.rotate()
+	return
+}
+
+const truncatedMarker = "\n[truncated]\n"
+
+// limit renders the report, dropping parts of it as described by MaxSize until
+// it fits.
+func (r *Reporter) limit(report *Report, render func(*Report) []byte) []byte {
+	data := render(report)
+	if r.MaxSize <= 0 || len(data) <= r.MaxSize {
+		return data
+	}
+	trimmed := *report
+	trimmed.Truncated = true
+	trimmed.Errors = append([]ReportError(nil), report.Errors...)
+	steps := []func(excess int) (again bool){
+		func(excess int) bool { return shrink(&trimmed.Goroutines, excess) },
+		func(int) bool {
+			trimmed.MemStats, trimmed.Build = nil, nil
+			return false
+		},
+		func(int) bool {
+			for i := range trimmed.Errors {
+				trimmed.Errors[i].Trace, trimmed.Errors[i].Fields = nil, nil
+			}
+			return false
+		},
+		func(int) bool {
+			trimmed.Errors = nil
+			return false
+		},
+		func(excess int) bool { return shrink(&trimmed.Error, excess) },
+	}
+	for _, step := range steps {
+		for again := true; again && len(data) > r.MaxSize; {
+			again = step(len(data) - r.MaxSize)
+			data = render(&trimmed)
+		}
+	}
+	return data
+}
+
+// shrink removes at least excess bytes from the end of *s, marking the cut. It
+// returns false once *s is empty.
+func shrink(s *string, excess int) bool {
+	if excess += len(truncatedMarker); excess < len(*s) {
+		*s = strings.ToValidUTF8((*s)[:len(*s)-excess], "") + truncatedMarker
+		return true
+	}
+	*s = ""
+	return false
+}
+
+// rotate removes the oldest reports beyond MaxReports.
+func (r *Reporter) rotate() {
+	if r.MaxReports <= 0 {
+		return
+	}
+	matches := reportCheck.Return(filepath.Glob(filepath.Join(r.Dir, "fault-*.txt"))).([]string)
+	sort.Strings(matches)
+	for len(matches) > r.MaxReports {
+		stem := strings.TrimSuffix(matches[0], ".txt")
+		reportCheck.Error(os.Remove(stem + ".txt"))
+		if err := os.Remove(stem + ".json"); err != nil && !os.IsNotExist(err) {
+			reportCheck.Error(err)
+		}
+		matches = matches[1:]
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	err := Chain(Traced(errors.New("first")), errors.New("second"))
+
+	path, werr := WriteReport(dir, err)
+	if werr != nil {
+		t.Fatal(werr)
+	}
+	text, _ := ioutil.ReadFile(path)
+	for _, expected := range []string{"error: ", "error 0: ", "error 1: second", "go version: go", "goroutines:\ngoroutine "} {
+		if !strings.Contains(string(text), expected) {
+			t.Error("Report missing", expected)
+		}
+	}
+	if !Release && !strings.Contains(string(text), "github.com/surullabs/fault.TestWriteReport") {
+		t.Error("Report missing trace")
+	}
+
+	data, _ := ioutil.ReadFile(strings.TrimSuffix(path, ".txt") + ".json")
+	var report Report
+	if jerr := json.Unmarshal(data, &report); jerr != nil {
+		t.Fatal(jerr)
+	}
+	if len(report.Errors) != 2 || report.Errors[1].Error != "second" || report.Errors[0].Fingerprint == "" {
+		t.Error("Unexpected errors in report", report.Errors)
+	}
+	if report.MemStats == nil || report.MemStats.Sys == 0 || report.Goroutines == "" || report.Truncated {
+		t.Error("Missing runtime information")
+	}
+}
+
+func TestReportRotation(t *testing.T) {
+	dir := t.TempDir()
+	r := &Reporter{Dir: dir, MaxReports: 2, MaxSize: 2048}
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path, err := r.Write(errors.New("failed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "fault-*"))
+	if len(matches) != 4 {
+		t.Error("Expected 2 reports of 2 files each found", matches)
+	}
+	for _, match := range matches {
+		if !strings.HasPrefix(match, strings.TrimSuffix(paths[2], ".txt")) &&
+			!strings.HasPrefix(match, strings.TrimSuffix(paths[3], ".txt")) {
+			t.Error("Unexpected report kept", match)
+		}
+		data, _ := ioutil.ReadFile(match)
+		if len(data) > r.MaxSize {
+			t.Error("Report exceeds size cap", match, len(data))
+		}
+		if strings.HasSuffix(match, ".json") {
+			var report Report
+			if err := json.Unmarshal(data, &report); err != nil {
+				t.Error("Invalid JSON report", match, err)
+			} else if !report.Truncated || report.Error != "failed" {
+				t.Error("Unexpected truncated report", match, report.Truncated, report.Error)
+			}
+		} else if !strings.Contains(string(data), "[truncated]") {
+			t.Error("Report not truncated", match)
+		}
+	}
+}
+
+func TestReportSizeCap(t *testing.T) {
+	err := Chain(Traced(errors.New(strings.Repeat("long message ", 100))), errors.New("second"))
+	report := NewReport(err)
+	for _, size := range []int{8, 256, 1024, 4096} {
+		r := &Reporter{MaxSize: size}
+		for _, format := range []string{"text", "json"} {
+			var data []byte
+			if format == "text" {
+				data = r.limit(report, (*Report).Text)
+			} else {
+				data = r.limit(report, (*Report).JSON)
+				var decoded Report
+				if jerr := json.Unmarshal(data, &decoded); jerr != nil {
+					t.Error("Invalid JSON for size", size, jerr)
+				} else if !decoded.Truncated {
+					t.Error("Truncation not recorded for size", size)
+				}
+			}
+			if size >= 1024 && len(data) > size {
+				t.Error("Expected", format, "report within", size, "found", len(data))
+			}
+		}
+	}
+	if report.Truncated || report.MemStats == nil || len(report.Errors) != 2 {
+		t.Error("Original report modified")
+	}
+}
+
+func TestReportOnPanic(t *testing.T) {
+	dir := t.TempDir()
+	defer func() {
+		if e := recover(); e == nil || e.(string) != "different panic" {
+			t.Error("Panic not propagated", e)
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, "fault-*.txt"))
+		if len(matches) != 1 {
+			t.Fatal("Expected one report found", matches)
+		}
+		if data, _ := ioutil.ReadFile(matches[0]); !strings.Contains(string(data), "error: panic: different panic") {
+			t.Error("Report missing panic")
+		}
+	}()
+	func() {
+		defer ReportOnPanic(dir)
+		panic("different panic")
+	}()
+}
@@ -0,0 +1,70 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+// Rollback is a stack of compensating actions which are run only when a fault
+// unwinds the function that owns it. It is meant for multi-step operations
+// where each completed step must be undone if a later step fails.
+//
+//	func ExportedMethod() (err error) {
+//		undo := check.Rollback()
+//		defer undo.Recover(&err)
+//
+//		check.Error(createFile())
+//		undo.OnFault(func() { check.Error(removeFile()) })
+//
+//		check.Error(updateIndex())
+//		undo.Commit()
+//		return
+//	}
+//
+// A Rollback must not be shared between calls or goroutines.
+type Rollback struct {
+	check *Checker
+	stack []func()
+}
+
+// Rollback returns a new, empty compensation stack tied to this checker.
+func (c *Checker) Rollback() *Rollback { return &Rollback{check: c} }
+
+// OnFault pushes fn onto the compensation stack. Compensations may themselves
+// raise faults, which are recovered and added to the resulting error.
+func (r *Rollback) OnFault(fn func()) {
+	r.stack = append(r.stack, fn)
+}
+
+// Commit discards all registered compensations. It should be called once the
+// operation has completed successfully.
+func (r *Rollback) Commit() { r.stack = nil }
+
+// Recover works like Checker.Recover. If a fault is recovered, all registered
+// compensations are run in reverse order of registration and any faults they
+// raise are appended to the error.
+func (r *Rollback) Recover(errPtr *error) {
+	r.RecoverPanic(errPtr, recover())
+}
+
+// RecoverPanic works like Checker.RecoverPanic, running the compensations
+// when panicked is a fault or a panic converted by the checker's PanicPolicy.
+func (r *Rollback) RecoverPanic(errPtr *error, panicked interface{}) {
+	r.check.RecoverPanic(errPtr, panicked)
+	if panicked == nil {
+		return
+	}
+
+	stack := r.stack
+	r.stack = nil
+	chain := &ErrorChain{}
+	chain.Append(*errPtr)
+	for i := len(stack) - 1; i >= 0; i-- {
+		chain.Append(r.compensate(stack[i]))
+	}
+	*errPtr = chain.AsError()
+}
+
+func (r *Rollback) compensate(fn func()) (err error) {
+	defer r.check.Recover(&err)
+	fn()
+	return
+}
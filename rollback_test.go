@@ -0,0 +1,92 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package fault
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var rollbackCheck = NewChecker().SetFaulter(Simple)
+
+func runRollback(steps []string, failAt int, commit bool, undone *[]string) (err error) {
+	undo := rollbackCheck.Rollback()
+	defer undo.Recover(&err)
+
+	for i, step := range steps {
+		rollbackCheck.Truef(i != failAt, "%s failed", step)
+		name := step
+		undo.OnFault(func() {
+			*undone = append(*undone, name)
+			rollbackCheck.True(name != "bad", "undo bad failed")
+		})
+	}
+	if commit {
+		undo.Commit()
+	}
+	return
+}
+
+func TestRollback(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		steps  []string
+		failAt int
+		commit bool
+		err    string
+		undone string
+	}{
+		{"success", []string{"a", "b"}, -1, true, "", ""},
+		{"fail first", []string{"a", "b"}, 0, false, "a failed", ""},
+		{"fail last", []string{"a", "b", "c"}, 2, false, "c failed", "b,a"},
+		{"no fault no commit", []string{"a", "b"}, -1, false, "", ""},
+		{"failing undo", []string{"a", "bad", "c"}, 2, false, "c failed; undo bad failed", "bad,a"},
+	} {
+		t.Log(test.name)
+		var undone []string
+		err := runRollback(test.steps, test.failAt, test.commit, &undone)
+		if err == nil && test.err != "" {
+			t.Error("Expected error", test.err, "not found")
+		} else if err != nil && err.Error() != test.err {
+			t.Error("Expected", test.err, "found", err.Error())
+		}
+		if strings.Join(undone, ",") != test.undone {
+			t.Error("Expected undo", test.undone, "found", undone)
+		}
+	}
+}
+
+func TestRollbackNonFaultPanic(t *testing.T) {
+	undone := false
+	defer func() {
+		if e := recover(); e == nil || e.(string) != "different panic" {
+			t.Error("Not recovered")
+		}
+		if undone {
+			t.Error("Compensation run for non fault panic")
+		}
+	}()
+
+	func() (err error) {
+		undo := rollbackCheck.Rollback()
+		defer undo.Recover(&err)
+		undo.OnFault(func() { undone = true })
+		panic("different panic")
+	}()
+}
+
+func TestRollbackExistingError(t *testing.T) {
+	err := func() (err error) {
+		undo := rollbackCheck.Rollback()
+		defer undo.Recover(&err)
+		undo.OnFault(func() {})
+		err = errors.New("existing")
+		rollbackCheck.True(false, "error1")
+		return
+	}()
+	if err == nil || err.Error() != "error1; existing" {
+		t.Error("Unexpected error", err)
+	}
+}
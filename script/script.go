@@ -0,0 +1,287 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+/*
+Package script provides helpers for writing shell-like scripts in Go using
+package fault. Every command which fails raises a fault recording the command
+line, working directory and exit status.
+
+	var check = fault.NewChecker()
+
+	func release(version string) (err error) {
+		defer check.Recover(&err)
+		sh := script.New(check).InDir("build")
+		sh.Run("go", "test", "./...")
+		rev := sh.Output("git", "rev-parse", "HEAD")
+		sh.Env("VERSION="+version).Run("make", "dist")
+		files := sh.Pipe(script.Cmd("ls", "dist"), script.Cmd("grep", version))
+		...
+	}
+*/
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/surullabs/fault"
+)
+
+func init() { fault.HelperType(&Script{}) }
+
+// Script runs commands, raising faults using its checker when they fail.
+// Methods which configure a script return a modified copy and leave the
+// original unchanged.
+type Script struct {
+	check  fault.FaultCheck
+	dir    string
+	env    []string
+	tee    io.Writer
+	stdout io.Writer
+	stderr io.Writer
+	echo   io.Writer
+	dryRun bool
+}
+
+// New returns a script which raises faults using check. Output of commands
+// run using Run goes to os.Stdout and os.Stderr.
+func New(check fault.FaultCheck) *Script {
+	return &Script{check: check, stdout: os.Stdout, stderr: os.Stderr}
+}
+
+func (s *Script) with(fn func(*Script)) *Script {
+	copied := *s
+	copied.env = append([]string(nil), s.env...)
+	fn(&copied)
+	return &copied
+}
+
+// InDir returns a script which runs commands in dir.
+func (s *Script) InDir(dir string) *Script { return s.with(func(c *Script) { c.dir = dir }) }
+
+// Env returns a script which adds the given KEY=value pairs to the
+// environment of commands.
+func (s *Script) Env(kv ...string) *Script {
+	return s.with(func(c *Script) { c.env = append(c.env, kv...) })
+}
+
+// Tee returns a script which also writes the standard output of all commands
+// to w.
+func (s *Script) Tee(w io.Writer) *Script { return s.with(func(c *Script) { c.tee = w }) }
+
+// Stdio returns a script which sends the output of commands run using Run to
+// stdout and stderr. The standard error of commands run using Output and Pipe
+// also goes to stderr.
+func (s *Script) Stdio(stdout, stderr io.Writer) *Script {
+	return s.with(func(c *Script) { c.stdout, c.stderr = stdout, stderr })
+}
+
+// Echo returns a script which writes each command line to w before running it.
+func (s *Script) Echo(w io.Writer) *Script { return s.with(func(c *Script) { c.echo = w }) }
+
+// DryRun returns a script which echoes commands without running them. Output
+// and Pipe return an empty string.
+func (s *Script) DryRun() *Script {
+	return s.with(func(c *Script) {
+		c.dryRun = true
+		if c.echo == nil {
+			c.echo = c.stdout
+		}
+	})
+}
+
+// Cmd returns a command line for use with Pipe.
+func Cmd(name string, args ...string) []string { return append([]string{name}, args...) }
+
+// Run runs the command, sending its output to the script's stdout and stderr.
+func (s *Script) Run(name string, args ...string) {
+	s.pipe(s.stdout, Cmd(name, args...))
+}
+
+// Output runs the command and returns its standard output.
+func (s *Script) Output(name string, args ...string) string {
+	return s.Pipe(Cmd(name, args...))
+}
+
+// Pipe runs the commands as a pipeline, connecting the standard output of each
+// to the standard input of the next, and returns the standard output of the
+// last. Every command in the pipeline must succeed.
+func (s *Script) Pipe(cmds ...[]string) string {
+	var out bytes.Buffer
+	s.pipe(&out, cmds...)
+	return out.String()
+}
+
+// pipe runs the pipeline, writing the standard output of the last command to
+// stdout and the script's tee.
+func (s *Script) pipe(stdout io.Writer, cmds ...[]string) {
+	if len(cmds) == 0 {
+		return
+	}
+	lines := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		lines[i] = Quote(cmd...)
+	}
+	if s.echo != nil {
+		fmt.Fprintf(s.echo, "+ %s\n", strings.Join(lines, " | "))
+	}
+	if s.dryRun {
+		return
+	}
+
+	// Stages are connected by pipes, with stdins[i] read by stage i and
+	// stdouts[i] written by stage i. The parent's copy of each end is closed
+	// as soon as the stage using it has started, so that a stage which exits
+	// early closes the pipe and its writer receives SIGPIPE.
+	stages := make([]*exec.Cmd, len(cmds))
+	stderrs := make([]outputBuffer, len(cmds))
+	stdins := make([]*os.File, len(cmds))
+	stdouts := make([]*os.File, len(cmds))
+	closeStage := func(i int) {
+		// Close is a no-op for the nil ends of the first and last stage.
+		stdins[i].Close()
+		stdouts[i].Close()
+	}
+	for i, cmd := range cmds {
+		stages[i] = exec.Command(cmd[0], cmd[1:]...)
+		stages[i].Dir = s.dir
+		if len(s.env) > 0 {
+			stages[i].Env = append(os.Environ(), s.env...)
+		}
+		stderrs[i].limit = fault.DefaultOutputLimit
+		stages[i].Stderr = io.MultiWriter(s.stderr, &stderrs[i])
+		if i > 0 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				for j := 0; j < i; j++ {
+					closeStage(j)
+				}
+				s.check.Error(err)
+			}
+			stdins[i], stdouts[i-1] = r, w
+			stages[i].Stdin, stages[i-1].Stdout = r, w
+		}
+	}
+	// The head and tail of the output of the last command are kept for error
+	// reporting.
+	out := outputBuffer{limit: fault.DefaultOutputLimit}
+	last := stages[len(stages)-1]
+	last.Stdout = io.MultiWriter(stdout, &out)
+	if s.tee != nil {
+		last.Stdout = io.MultiWriter(stdout, &out, s.tee)
+	}
+
+	for i, stage := range stages {
+		if err := stage.Start(); err != nil {
+			// Closing the remaining pipes lets the started stages finish
+			// even if they have children which outlive them.
+			for j := i; j < len(stages); j++ {
+				closeStage(j)
+			}
+			for _, started := range stages[:i] {
+				started.Process.Kill()
+				started.Wait()
+			}
+			s.check.Error(s.describe(fault.NewCommandError(nil, err, fault.DefaultOutputLimit), lines[i]))
+		}
+		closeStage(i)
+	}
+
+	var failed error
+	for i, stage := range stages {
+		err := stage.Wait()
+		if err == nil || failed != nil || (stage != last && brokenPipe(err)) {
+			continue
+		}
+		cmdErr := fault.NewCommandError(nil, err, fault.DefaultOutputLimit)
+		if stage == last {
+			cmdErr.Output = out.Truncated()
+		}
+		cmdErr.Stderr = stderrs[i].Truncated()
+		failed = s.describe(cmdErr, lines[i])
+	}
+	s.check.Error(failed)
+}
+
+// brokenPipe returns true if err reports that a command was killed by SIGPIPE,
+// which happens to a stage whose reader exits without consuming all its
+// output, as head does.
+func brokenPipe(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGPIPE
+}
+
+// outputBuffer keeps the head and tail of the output written to it, holding at
+// most limit bytes plus a little slack so that Truncated can cut on rune
+// boundaries.
+type outputBuffer struct {
+	limit      int
+	size       int
+	head, tail []byte
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.size += n
+	headMax := b.limit/2 + utf8.UTFMax
+	tailMax := b.limit - b.limit/2 + utf8.UTFMax
+	if room := headMax - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+	if len(p) >= tailMax {
+		b.tail = append(b.tail[:0], p[len(p)-tailMax:]...)
+	} else if excess := len(b.tail) + len(p) - tailMax; excess > 0 {
+		b.tail = append(b.tail[:copy(b.tail, b.tail[excess:])], p...)
+	} else {
+		b.tail = append(b.tail, p...)
+	}
+	return n, nil
+}
+
+// Truncated returns the output kept by the buffer as it would be truncated by
+// fault.Truncate.
+func (b *outputBuffer) Truncated() fault.TruncatedOutput {
+	kept := append(append([]byte(nil), b.head...), b.tail...)
+	t := fault.Truncate(kept, b.limit)
+	t.Size = b.size
+	t.Elided += b.size - len(kept)
+	return t
+}
+
+// describe attaches the command line and working directory to err.
+func (s *Script) describe(err error, line string) error {
+	dir := s.dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	return fault.WithFields(err, fault.Field{Key: "command", Value: line}, fault.Field{Key: "dir", Value: dir})
+}
+
+// Quote returns the arguments as a command line which can be pasted into a
+// POSIX shell.
+func Quote(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
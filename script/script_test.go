@@ -0,0 +1,180 @@
+// Copyright 2014, Surul Software Labs GmbH
+// All rights reserved.
+
+package script
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/surullabs/fault"
+)
+
+var check = fault.NewChecker().SetFaulter(fault.Simple)
+
+func run(fn func()) (err error) {
+	defer check.Recover(&err)
+	fn()
+	return
+}
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sh := New(check).Stdio(&stdout, &stderr)
+
+	if err := run(func() { sh.Run("true") }); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if err := run(func() { sh.Run("/bin/sh", "-c", "echo out; echo err >&2") }); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Error("Unexpected output", stdout.String(), stderr.String())
+	}
+
+	err := run(func() { sh.InDir("/").Run("/bin/sh", "-c", "echo failing >&2; exit 4") })
+	var cmdErr *fault.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != 4 || cmdErr.Stderr.Head != "failing\n" {
+		t.Fatal("Unexpected error", err)
+	}
+	fields := fault.Fields(err)
+	if len(fields) != 5 || fields[0].Value != `/bin/sh -c 'echo failing >&2; exit 4'` || fields[1].Value != "/" {
+		t.Error("Unexpected fields", fields)
+	}
+
+	err = run(func() { sh.Run("false") })
+	if !errors.As(err, &cmdErr) || cmdErr.Code != 1 {
+		t.Error("Unexpected error", err)
+	}
+	wd, _ := os.Getwd()
+	if fields := fault.Fields(err); len(fields) < 2 || fields[1].Value != wd {
+		t.Error("Working directory not recorded", fields)
+	}
+
+	if err = run(func() { sh.Run("/nonexistent/binary") }); err == nil || !strings.Contains(err.Error(), "command=/nonexistent/binary") {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	sh := New(check).Stdio(&bytes.Buffer{}, &bytes.Buffer{})
+	var out string
+	var tee bytes.Buffer
+	err := run(func() {
+		out = sh.Env("FAULT_SCRIPT_TEST=value").InDir("/").Tee(&tee).Output("/bin/sh", "-c", "echo $FAULT_SCRIPT_TEST $(pwd)")
+	})
+	if err != nil || out != "value /\n" || tee.String() != out {
+		t.Error("Unexpected output", out, tee.String(), err)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	sh := New(check).Stdio(&bytes.Buffer{}, &bytes.Buffer{})
+	var out string
+	err := run(func() {
+		out = sh.Pipe(Cmd("/bin/sh", "-c", "echo a; echo b; echo ab"), Cmd("grep", "b"), Cmd("wc", "-l"))
+	})
+	if err != nil || strings.TrimSpace(out) != "2" {
+		t.Error("Unexpected output", out, err)
+	}
+
+	err = run(func() { sh.Pipe(Cmd("false"), Cmd("cat")) })
+	if fields := fault.Fields(err); len(fields) == 0 || fields[0].Value != "false" {
+		t.Error("Failing stage not reported", err)
+	}
+	err = run(func() { sh.Pipe(Cmd("/bin/sh", "-c", "yes | head -c 1000000"), Cmd("/nonexistent/binary")) })
+	if fields := fault.Fields(err); len(fields) == 0 || fields[0].Value != "/nonexistent/binary" {
+		t.Error("Failing start not reported", err)
+	}
+	if sh.Pipe() != "" {
+		t.Error("Empty pipe produced output")
+	}
+}
+
+func TestPipeEarlyExit(t *testing.T) {
+	sh := New(check).Stdio(&bytes.Buffer{}, &bytes.Buffer{})
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var out string
+		err := run(func() { out = sh.Pipe(Cmd("yes"), Cmd("head", "-1")) })
+		done <- result{out, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil || r.out != "y\n" {
+			t.Error("Unexpected output", r.out, r.err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Pipeline did not finish")
+	}
+}
+
+func TestOutputBuffer(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789é", 1000))
+	for _, limit := range []int{8, 64, 4096} {
+		for _, chunk := range []int{1, 7, 100, len(data)} {
+			b := outputBuffer{limit: limit}
+			for i := 0; i < len(data); i += chunk {
+				end := i + chunk
+				if end > len(data) {
+					end = len(data)
+				}
+				b.Write(data[i:end])
+			}
+			if expected := fault.Truncate(data, limit); b.Truncated() != expected {
+				t.Error("Unexpected truncation for limit", limit, "chunk", chunk, b.Truncated(), expected)
+			}
+			if len(b.head)+len(b.tail) > limit+8 {
+				t.Error("Buffer exceeds limit", limit, len(b.head), len(b.tail))
+			}
+		}
+	}
+	small := outputBuffer{limit: 64}
+	small.Write([]byte("short"))
+	if small.Truncated() != fault.Truncate([]byte("short"), 64) {
+		t.Error("Short output changed", small.Truncated())
+	}
+
+	sh := New(check).Stdio(&bytes.Buffer{}, &bytes.Buffer{})
+	err := run(func() { sh.Run("/bin/sh", "-c", "head -c 1000000 /dev/zero | tr '\\0' x >&2; exit 1") })
+	var cmdErr *fault.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Stderr.Size != 1000000 ||
+		len(cmdErr.Stderr.Head)+len(cmdErr.Stderr.Tail) != fault.DefaultOutputLimit {
+		t.Error("Unexpected stderr", err)
+	}
+}
+
+func TestEchoDryRun(t *testing.T) {
+	var stdout, echo bytes.Buffer
+	sh := New(check).Stdio(&stdout, &bytes.Buffer{})
+	err := run(func() {
+		sh.Echo(&echo).Run("echo", "hello world")
+		sh.DryRun().Run("false")
+		if sh.DryRun().Output("false") != "" {
+			t.Error("Dry run produced output")
+		}
+	})
+	if err != nil {
+		t.Error("Unexpected error", err)
+	}
+	if echo.String() != "+ echo 'hello world'\n" {
+		t.Error("Unexpected echo", echo.String())
+	}
+	if stdout.String() != "hello world\n+ false\n+ false\n" {
+		t.Error("Unexpected stdout", stdout.String())
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if q := Quote("ls", "-l", "a b", "it's", ""); q != `ls -l 'a b' 'it'\''s' ''` {
+		t.Error("Unexpected quoting", q)
+	}
+}